@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go_interp/interp/compiler"
+	"go_interp/interp/vm"
+)
+
+// BYTECODE_EXT 字节码文件的扩展名; 脚本旁边同名的字节码文件即为它的缓存
+const BYTECODE_EXT = ".mkc"
+
+// bytecodePath 返回脚本对应的字节码文件名, 如main.mk对应main.mkc
+func bytecodePath(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + BYTECODE_EXT
+}
+
+func readBytecode(path string) (*compiler.File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Decode(bytes.NewReader(data))
+}
+
+func writeBytecode(path string, f *compiler.File) error {
+	var buf bytes.Buffer
+	if err := compiler.Encode(&buf, f); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// sourcePath 返回写入字节码文件output中的源文件路径: 相对于output所在的目录,
+// 这样从其他目录执行字节码文件时也能找到源文件; 无法得到相对路径时使用绝对路径
+func sourcePath(output, filename string) string {
+	if filename == STDIN_NAME {
+		return ""
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return ""
+	}
+	dir, err := filepath.Abs(filepath.Dir(output))
+	if err != nil {
+		return abs
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil {
+		return rel
+	}
+	return abs
+}
+
+// bytecodeSource 返回字节码文件path记录的源文件的路径. 从标准输入编译时没有源文件;
+// 字节码文件本身来自标准输入时无法确定相对路径的起点, 同样返回""
+func bytecodeSource(path string, f *compiler.File) string {
+	src := f.SourcePath
+	if src == "" || filepath.IsAbs(src) {
+		return src
+	}
+	if path == STDIN_NAME {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), src)
+}
+
+// compile 解析并编译源码, output为将要写入的字节码文件. 出错时将错误写入stderr并返回非0的退出码
+func (c *cli) compile(filename, source, output string) (*compiler.File, int) {
+	program := c.parse(filename, source)
+	if program == nil {
+		return nil, exitSyntaxError
+	}
+	bytecode, err := compiler.Compile(program)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "go_interp: compile error: %s\n", err)
+		return nil, exitRuntimeError
+	}
+	return &compiler.File{
+		SourceHash: compiler.HashSource(source),
+		Filename:   filename,
+		SourcePath: sourcePath(output, filename),
+		Bytecode:   bytecode,
+	}, exitOK
+}
+
+// loadBytecode 返回脚本的字节码. 脚本旁边的字节码文件与源码一致时直接使用;
+// 字节码文件存在但已过期(源码被修改或由其他版本生成)时重新编译并更新它.
+// 缓存只由build创建, run不会在脚本旁边新建文件
+func (c *cli) loadBytecode(filename, source string) (*compiler.File, int) {
+	if filename == STDIN_NAME {
+		return c.compile(filename, source, "")
+	}
+
+	cachePath := bytecodePath(filename)
+	cached, err := readBytecode(cachePath)
+	if err == nil && cached.SourceHash == compiler.HashSource(source) && cached.Filename == filename {
+		return cached, exitOK
+	}
+
+	f, code := c.compile(filename, source, cachePath)
+	if code == exitOK && !os.IsNotExist(err) {
+		// 缓存写入失败不影响执行, 下次运行时会再次尝试
+		_ = writeBytecode(cachePath, f)
+	}
+	return f, code
+}
+
+// runBytecode 执行build生成的字节码文件. 文件中记录的源文件仍然存在时, 用它显示出错的源码;
+// 源文件已被修改时重新编译, 并更新字节码文件. 源文件的位置相对于字节码文件而不是当前目录,
+// 无法确定源文件时直接执行字节码
+func (c *cli) runBytecode(path, data string) int {
+	f, err := compiler.Decode(strings.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(c.stderr, "go_interp: %s: %s\n", path, err)
+		return exitUsage
+	}
+
+	var source string
+	if src := bytecodeSource(path, f); src != "" {
+		if data, err := os.ReadFile(src); err == nil {
+			source = string(data)
+			if compiler.HashSource(source) != f.SourceHash {
+				var code int
+				if f, code = c.compile(f.Filename, source, path); code != exitOK {
+					return code
+				}
+				f.SourcePath = sourcePath(path, src)
+				if path != STDIN_NAME {
+					_ = writeBytecode(path, f)
+				}
+			}
+		}
+	}
+	return c.report(source, vm.NewWithBuiltins(f.Bytecode, c.builtins).Run())
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strings"
 
 	"go_interp/model/token"
 )
@@ -10,6 +11,10 @@ type Node interface {
 	// TokenLiteral returns the literal string which was parsed to create this node.
 	TokenLiteral() string
 	String() string
+	// Pos returns the position of the first character belonging to the node.
+	Pos() token.Pos
+	// End returns the position immediately after the node.
+	End() token.Pos
 }
 
 // Statement no result value
@@ -33,11 +38,37 @@ func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
 func (i *IntegerLiteral) expressionNode() {}
+func (i *IntegerLiteral) Pos() token.Pos {
+	return i.Token.Pos
+}
+func (i *IntegerLiteral) End() token.Pos {
+	return i.Token.End
+}
 
 func (i *IntegerLiteral) String() string {
 	return i.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token // token.FLOAT 词法单元
+	Value float64
+}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+func (f *FloatLiteral) expressionNode() {}
+func (f *FloatLiteral) Pos() token.Pos {
+	return f.Token.Pos
+}
+func (f *FloatLiteral) End() token.Pos {
+	return f.Token.End
+}
+
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
 type PrefixExpression struct {
 	Token    token.Token // 前缀词法单元, 如!, -等
 	Operator string
@@ -49,6 +80,15 @@ func (p *PrefixExpression) TokenLiteral() string {
 }
 
 func (p *PrefixExpression) expressionNode() {}
+func (p *PrefixExpression) Pos() token.Pos {
+	return p.Token.Pos
+}
+func (p *PrefixExpression) End() token.Pos {
+	if p.Right != nil {
+		return p.Right.End()
+	}
+	return p.Token.End
+}
 
 func (p *PrefixExpression) String() string {
 	var out bytes.Buffer
@@ -71,6 +111,18 @@ func (p *InfixExpression) TokenLiteral() string {
 }
 
 func (p *InfixExpression) expressionNode() {}
+func (p *InfixExpression) Pos() token.Pos {
+	if p.Left != nil {
+		return p.Left.Pos()
+	}
+	return p.Token.Pos
+}
+func (p *InfixExpression) End() token.Pos {
+	if p.Right != nil {
+		return p.Right.End()
+	}
+	return p.Token.End
+}
 
 func (p *InfixExpression) String() string {
 	var out bytes.Buffer
@@ -96,3 +148,356 @@ func (b *Boolean) String() string {
 }
 
 func (b *Boolean) expressionNode() {}
+func (b *Boolean) Pos() token.Pos {
+	return b.Token.Pos
+}
+func (b *Boolean) End() token.Pos {
+	return b.Token.End
+}
+
+type IfExpression struct {
+	Token       token.Token // token.IF 词法单元
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (i *IfExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *IfExpression) expressionNode() {}
+func (i *IfExpression) Pos() token.Pos {
+	return i.Token.Pos
+}
+func (i *IfExpression) End() token.Pos {
+	if i.Alternative != nil {
+		return i.Alternative.End()
+	}
+	return i.Consequence.End()
+}
+
+func (i *IfExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("if")
+	out.WriteString(i.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(i.Consequence.String())
+	if i.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(i.Alternative.String())
+	}
+	return out.String()
+}
+
+type FunctionLiteral struct {
+	Token      token.Token // token.FUNCTION 词法单元
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (f *FunctionLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FunctionLiteral) expressionNode() {}
+func (f *FunctionLiteral) Pos() token.Pos {
+	return f.Token.Pos
+}
+func (f *FunctionLiteral) End() token.Pos {
+	return f.Body.End()
+}
+
+func (f *FunctionLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(f.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+type CallExpression struct {
+	Token     token.Token // token.LPAREN 词法单元
+	Function  Expression  // 标识符或函数字面量
+	Arguments []Expression
+	Rparen    token.Token // token.RPAREN 词法单元
+}
+
+func (c *CallExpression) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *CallExpression) expressionNode() {}
+func (c *CallExpression) Pos() token.Pos {
+	return c.Function.Pos()
+}
+func (c *CallExpression) End() token.Pos {
+	return c.Rparen.End
+}
+
+func (c *CallExpression) String() string {
+	var out bytes.Buffer
+	args := []string{}
+	for _, a := range c.Arguments {
+		args = append(args, a.String())
+	}
+	out.WriteString(c.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
+type StringLiteral struct {
+	Token token.Token // token.STRING 词法单元, Literal为解码后的值
+	Value string
+}
+
+func (s *StringLiteral) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *StringLiteral) expressionNode() {}
+func (s *StringLiteral) Pos() token.Pos {
+	return s.Token.Pos
+}
+func (s *StringLiteral) End() token.Pos {
+	return s.Token.End
+}
+
+func (s *StringLiteral) String() string {
+	return `"` + escapeString(s.Value) + `"`
+}
+
+// InterpolatedString 形如"a${x}b"的字符串, Parts依次为文本(*StringLiteral)和插值表达式
+type InterpolatedString struct {
+	Token token.Token // token.INTERP_STRING 词法单元
+	Parts []Expression
+}
+
+func (i *InterpolatedString) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *InterpolatedString) expressionNode() {}
+func (i *InterpolatedString) Pos() token.Pos {
+	return i.Token.Pos
+}
+func (i *InterpolatedString) End() token.Pos {
+	return i.Token.End
+}
+
+func (i *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString(`"`)
+	for _, part := range i.Parts {
+		if lit, ok := part.(*StringLiteral); ok {
+			out.WriteString(escapeString(lit.Value))
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString(`"`)
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // token.LBRACKET 词法单元
+	Elements []Expression
+	Rbracket token.Token // token.RBRACKET 词法单元
+}
+
+func (a *ArrayLiteral) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *ArrayLiteral) expressionNode() {}
+func (a *ArrayLiteral) Pos() token.Pos {
+	return a.Token.Pos
+}
+func (a *ArrayLiteral) End() token.Pos {
+	return a.Rbracket.End
+}
+
+func (a *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// IndexExpression 下标访问, 如arr[1]; 负数下标从末尾开始计数
+type IndexExpression struct {
+	Token    token.Token // token.LBRACKET 词法单元
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // token.RBRACKET 词法单元
+}
+
+func (i *IndexExpression) TokenLiteral() string {
+	return i.Token.Literal
+}
+
+func (i *IndexExpression) expressionNode() {}
+func (i *IndexExpression) Pos() token.Pos {
+	return i.Left.Pos()
+}
+func (i *IndexExpression) End() token.Pos {
+	return i.Rbracket.End
+}
+
+func (i *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString("[")
+	out.WriteString(i.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+// SliceExpression 切片, 如arr[1:3]; Low和High可以省略, 此时为nil
+type SliceExpression struct {
+	Token    token.Token // token.LBRACKET 词法单元
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Token // token.RBRACKET 词法单元
+}
+
+func (s *SliceExpression) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *SliceExpression) expressionNode() {}
+func (s *SliceExpression) Pos() token.Pos {
+	return s.Left.Pos()
+}
+func (s *SliceExpression) End() token.Pos {
+	return s.Rbracket.End
+}
+
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+// AssignExpression 赋值或复合赋值, 如x = 1, arr[0] += 2; Target为Identifier或IndexExpression
+type AssignExpression struct {
+	Token    token.Token // 赋值运算符词法单元
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (a *AssignExpression) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *AssignExpression) expressionNode() {}
+func (a *AssignExpression) Pos() token.Pos {
+	return a.Target.Pos()
+}
+func (a *AssignExpression) End() token.Pos {
+	return a.Value.End()
+}
+
+func (a *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(a.Target.String())
+	out.WriteString(a.Operator)
+	out.WriteString(a.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// HashLiteral 哈希表字面量, 如{"a": 1}; Pairs保持源码中的顺序
+type HashLiteral struct {
+	Token  token.Token // token.LBRACE 词法单元
+	Pairs  []HashPair
+	Rbrace token.Token // token.RBRACE 词法单元
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (h *HashLiteral) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+func (h *HashLiteral) expressionNode() {}
+func (h *HashLiteral) Pos() token.Pos {
+	return h.Token.Pos
+}
+func (h *HashLiteral) End() token.Pos {
+	return h.Rbrace.End
+}
+
+func (h *HashLiteral) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// escapeString 转义字符串中的特殊字符, 使String()的输出可以被重新解析
+func escapeString(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case 0:
+			out.WriteString(`\0`)
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				out.WriteString(`\$`)
+			} else {
+				out.WriteByte(c)
+			}
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"strings"
 
 	"go_interp/model/token"
 )
@@ -9,6 +10,8 @@ import (
 // Program AST的根节点
 type Program struct {
 	Statements []Statement
+	// Comments 源码中的全部注释, 按出现顺序排列; 仅当词法分析器开启注释输出时才会填充
+	Comments []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -19,6 +22,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Pos{}
+}
+
+func (p *Program) End() token.Pos {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Pos{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -36,6 +53,18 @@ type LetStatement struct {
 }
 
 func (l *LetStatement) statementNode() {}
+func (l *LetStatement) Pos() token.Pos {
+	return l.Token.Pos
+}
+func (l *LetStatement) End() token.Pos {
+	if l.Value != nil {
+		return l.Value.End()
+	}
+	if l.Name != nil {
+		return l.Name.End()
+	}
+	return l.Token.End
+}
 func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
@@ -62,6 +91,15 @@ type ReturnStatement struct {
 }
 
 func (r *ReturnStatement) statementNode() {}
+func (r *ReturnStatement) Pos() token.Pos {
+	return r.Token.Pos
+}
+func (r *ReturnStatement) End() token.Pos {
+	if r.Value != nil {
+		return r.Value.End()
+	}
+	return r.Token.End
+}
 func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
@@ -78,6 +116,103 @@ func (l *ReturnStatement) String() string {
 	return out.String()
 }
 
+// WhileStatement while (Condition) { Body }
+type WhileStatement struct {
+	Token     token.Token // token.WHILE 词法单元
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (w *WhileStatement) statementNode() {}
+func (w *WhileStatement) Pos() token.Pos {
+	return w.Token.Pos
+}
+func (w *WhileStatement) End() token.Pos {
+	return w.Body.End()
+}
+func (w *WhileStatement) TokenLiteral() string {
+	return w.Token.Literal
+}
+
+func (w *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while (")
+	out.WriteString(w.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(w.Body.String())
+	return out.String()
+}
+
+// ForStatement for (Variable in Iterable) { Body }
+type ForStatement struct {
+	Token    token.Token // token.FOR 词法单元
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (f *ForStatement) statementNode() {}
+func (f *ForStatement) Pos() token.Pos {
+	return f.Token.Pos
+}
+func (f *ForStatement) End() token.Pos {
+	return f.Body.End()
+}
+func (f *ForStatement) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	out.WriteString(f.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+// BreakStatement 结束所在的最内层循环
+type BreakStatement struct {
+	Token token.Token // token.BREAK 词法单元
+}
+
+func (b *BreakStatement) statementNode() {}
+func (b *BreakStatement) Pos() token.Pos {
+	return b.Token.Pos
+}
+func (b *BreakStatement) End() token.Pos {
+	return b.Token.End
+}
+func (b *BreakStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BreakStatement) String() string {
+	return b.Token.Literal + ";"
+}
+
+// ContinueStatement 跳过本次循环的剩余部分, 进入下一次循环
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE 词法单元
+}
+
+func (c *ContinueStatement) statementNode() {}
+func (c *ContinueStatement) Pos() token.Pos {
+	return c.Token.Pos
+}
+func (c *ContinueStatement) End() token.Pos {
+	return c.Token.End
+}
+func (c *ContinueStatement) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *ContinueStatement) String() string {
+	return c.Token.Literal + ";"
+}
+
 // ExpressionStatement expression 并不是真正的语句, 相当于一层封装; 表达式语句存在的意义: x + 10; 有效!
 type ExpressionStatement struct {
 	Token      token.Token // 该表达式中的第一个词法单元
@@ -85,6 +220,15 @@ type ExpressionStatement struct {
 }
 
 func (e *ExpressionStatement) statementNode() {}
+func (e *ExpressionStatement) Pos() token.Pos {
+	return e.Token.Pos
+}
+func (e *ExpressionStatement) End() token.Pos {
+	if e.Expression != nil {
+		return e.Expression.End()
+	}
+	return e.Token.End
+}
 func (e *ExpressionStatement) TokenLiteral() string {
 	return e.Token.Literal
 }
@@ -102,6 +246,12 @@ type Identifier struct {
 }
 
 func (i *Identifier) expressionNode() {}
+func (i *Identifier) Pos() token.Pos {
+	return i.Token.Pos
+}
+func (i *Identifier) End() token.Pos {
+	return i.Token.End
+}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
@@ -109,3 +259,59 @@ func (i *Identifier) TokenLiteral() string {
 func (i *Identifier) String() string {
 	return i.Value
 }
+
+// BlockStatement 由'{'和'}'包围的一组语句
+type BlockStatement struct {
+	Token      token.Token // token.LBRACE 词法单元
+	Statements []Statement
+	Rbrace     token.Token // token.RBRACE 词法单元
+}
+
+func (b *BlockStatement) statementNode() {}
+func (b *BlockStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+func (b *BlockStatement) Pos() token.Pos {
+	return b.Token.Pos
+}
+func (b *BlockStatement) End() token.Pos {
+	return b.Rbrace.End
+}
+
+func (b *BlockStatement) String() string {
+	var out bytes.Buffer
+	for _, s := range b.Statements {
+		out.WriteString(s.String())
+	}
+	return out.String()
+}
+
+// Comment 一个行注释或块注释, 不参与求值, 供格式化、文档等工具使用
+type Comment struct {
+	Token token.Token // token.COMMENT 词法单元
+}
+
+func (c *Comment) TokenLiteral() string {
+	return c.Token.Literal
+}
+func (c *Comment) Pos() token.Pos {
+	return c.Token.Pos
+}
+func (c *Comment) End() token.Pos {
+	return c.Token.End
+}
+
+func (c *Comment) String() string {
+	return c.Token.Literal
+}
+
+// Text returns the comment without its delimiters and surrounding spaces.
+func (c *Comment) Text() string {
+	text := c.Token.Literal
+	if strings.HasPrefix(text, "//") {
+		return strings.TrimSpace(text[2:])
+	}
+	text = strings.TrimPrefix(text, "/*")
+	text = strings.TrimSuffix(text, "*/")
+	return strings.TrimSpace(text)
+}
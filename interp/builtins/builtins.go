@@ -0,0 +1,158 @@
+// Package builtins implements the functions that are available in every
+// program without being defined, such as len and puts. Host programs can
+// add their own functions to a Registry and pass it to the evaluator or the VM.
+package builtins
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/object"
+)
+
+// Any 参数类型为Any时不检查该参数的类型
+const Any object.ObjectType = "ANY"
+
+// Variadic 作为MaxArgs时表示参数个数不限
+const Variadic = -1
+
+// Context 内置函数执行时可以使用的宿主环境
+type Context struct {
+	// Out puts和print的输出
+	Out io.Writer
+}
+
+// Builtin 用Go实现的内置函数. 调用前由Registry检查参数个数和类型,
+// Fn只会收到符合声明的参数
+type Builtin struct {
+	Name    string
+	MinArgs int
+	MaxArgs int
+	// Params 各位置参数接受的类型, 参数多于Params时按最后一个检查; 为空时不检查
+	Params []object.ObjectType
+	Fn     func(ctx *Context, args []object.Object) object.Object
+}
+
+// Registry 内置函数表. 求值器在环境中找不到标识符时到环境的内置函数表中查找
+// (见object.NewEnvironmentWithBuiltins), 虚拟机则使用vm.NewWithBuiltins指定的表;
+// 没有指定时使用Default
+type Registry struct {
+	Out      io.Writer
+	builtins map[string]*object.Builtin
+}
+
+// NewRegistry returns an empty registry writing to out.
+func NewRegistry(out io.Writer) *Registry {
+	return &Registry{
+		Out:      out,
+		builtins: make(map[string]*object.Builtin),
+	}
+}
+
+// NewDefaultRegistry returns a registry holding the standard builtins.
+func NewDefaultRegistry(out io.Writer) *Registry {
+	r := NewRegistry(out)
+	for _, b := range standardBuiltins() {
+		r.Register(b)
+	}
+	return r
+}
+
+// Default 标准内置函数表, 输出到os.Stdout
+var Default = NewDefaultRegistry(os.Stdout)
+
+// Register adds b, replacing any builtin with the same name.
+func (r *Registry) Register(b *Builtin) {
+	r.builtins[b.Name] = &object.Builtin{
+		Name: b.Name,
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs(b, args); err != nil {
+				return err
+			}
+			return b.Fn(&Context{Out: r.Out}, args)
+		},
+	}
+}
+
+func (r *Registry) Lookup(name string) (*object.Builtin, bool) {
+	b, ok := r.builtins[name]
+	return b, ok
+}
+
+// Register adds b to the Default registry.
+func Register(b *Builtin) {
+	Default.Register(b)
+}
+
+// Lookup finds name in the Default registry.
+func Lookup(name string) (*object.Builtin, bool) {
+	return Default.Lookup(name)
+}
+
+func checkArgs(b *Builtin, args []object.Object) *object.Error {
+	if len(args) < b.MinArgs || (b.MaxArgs != Variadic && len(args) > b.MaxArgs) {
+		return &object.Error{
+			Code:    diagnostic.WrongArgumentCount,
+			Message: fmt.Sprintf("wrong number of arguments to %s: want=%s, got=%d", b.Name, arity(b), len(args)),
+		}
+	}
+	if len(b.Params) == 0 {
+		return nil
+	}
+
+	for i, arg := range args {
+		want := b.Params[len(b.Params)-1]
+		if i < len(b.Params) {
+			want = b.Params[i]
+		}
+		if want != Any && arg.Type() != want {
+			return ArgumentError(b.Name, i, arg, want)
+		}
+	}
+	return nil
+}
+
+// arity 参数个数的文字描述, 如"1", "1..3", "1+"
+func arity(b *Builtin) string {
+	switch {
+	case b.MaxArgs == Variadic:
+		return fmt.Sprintf("%d+", b.MinArgs)
+	case b.MinArgs == b.MaxArgs:
+		return fmt.Sprintf("%d", b.MinArgs)
+	default:
+		return fmt.Sprintf("%d..%d", b.MinArgs, b.MaxArgs)
+	}
+}
+
+// ArgumentError reports that argument index (counted from 0) of the builtin
+// name has an unsupported type.
+func ArgumentError(name string, index int, arg object.Object, want ...object.ObjectType) *object.Error {
+	if len(want) == 0 {
+		return &object.Error{
+			Code:    diagnostic.ArgumentType,
+			Message: fmt.Sprintf("argument %d to %s has unsupported type %s", index+1, name, arg.Type()),
+		}
+	}
+
+	types := make([]string, len(want))
+	for i, t := range want {
+		types[i] = string(t)
+	}
+	// 多个类型写作"A, B or C"
+	expected := types[len(types)-1]
+	if len(types) > 1 {
+		expected = strings.Join(types[:len(types)-1], ", ") + " or " + expected
+	}
+	return &object.Error{
+		Code:    diagnostic.ArgumentType,
+		Message: fmt.Sprintf("argument %d to %s must be %s, got %s", index+1, name, expected, arg.Type()),
+	}
+}
+
+// Errorf reports an argument that has the right type but an invalid value.
+func Errorf(format string, a ...interface{}) *object.Error {
+	return &object.Error{Code: diagnostic.InvalidArgument, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,189 @@
+package builtins
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/object"
+)
+
+func ints(values ...int64) *object.Array {
+	arr := &object.Array{}
+	for _, v := range values {
+		arr.Elements = append(arr.Elements, integer(v))
+	}
+	return arr
+}
+
+func integer(v int64) *object.Integer {
+	return &object.Integer{Value: v}
+}
+
+func str(s string) *object.String {
+	return &object.String{Value: s}
+}
+
+func call(t *testing.T, r *Registry, name string, args ...object.Object) object.Object {
+	t.Helper()
+	b, ok := r.Lookup(name)
+	if !ok {
+		t.Fatalf("builtin %s not registered", name)
+	}
+	return b.Fn(args...)
+}
+
+func TestStandardBuiltins(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []object.Object
+		expected string
+	}{
+		{"len", []object.Object{str("hello")}, "5"},
+		{"len", []object.Object{str("你好")}, "2"},
+		{"len", []object.Object{ints(1, 2, 3)}, "3"},
+		{"type", []object.Object{ints()}, "ARRAY"},
+		{"type", []object.Object{object.NULL}, "NULL"},
+		{"str", []object.Object{&object.Float{Value: 2}}, "2.0"},
+		{"str", []object.Object{ints(1, 2)}, "[1, 2]"},
+		{"int", []object.Object{&object.Float{Value: -2.7}}, "-2"},
+		{"int", []object.Object{str(" 42 ")}, "42"},
+		{"int", []object.Object{object.TRUE}, "1"},
+		{"first", []object.Object{ints(1, 2, 3)}, "1"},
+		{"first", []object.Object{ints()}, "null"},
+		{"last", []object.Object{ints(1, 2, 3)}, "3"},
+		{"last", []object.Object{ints()}, "null"},
+		{"rest", []object.Object{ints(1, 2, 3)}, "[2, 3]"},
+		{"rest", []object.Object{ints()}, "null"},
+		{"push", []object.Object{ints(1), str("a")}, `[1, "a"]`},
+		{"range", []object.Object{integer(4)}, "[0, 1, 2, 3]"},
+		{"range", []object.Object{integer(2), integer(5)}, "[2, 3, 4]"},
+		{"range", []object.Object{integer(5), integer(0), integer(-2)}, "[5, 3, 1]"},
+		{"range", []object.Object{integer(0)}, "[]"},
+		// 接近整数范围两端时不会溢出回绕
+		{"range", []object.Object{integer(math.MaxInt64 - 7), integer(math.MaxInt64), integer(10)}, "[9223372036854775800]"},
+		{"range", []object.Object{integer(math.MaxInt64 - 2), integer(math.MaxInt64)}, "[9223372036854775805, 9223372036854775806]"},
+		{"range", []object.Object{integer(math.MinInt64 + 5), integer(math.MinInt64), integer(-10)}, "[-9223372036854775803]"},
+	}
+
+	r := NewDefaultRegistry(&bytes.Buffer{})
+	for _, tt := range tests {
+		result := call(t, r, tt.name, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s(%v): wrong result. expected=%q, got=%q", tt.name, tt.args, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestBuiltinsDoNotModifyArguments(t *testing.T) {
+	r := NewDefaultRegistry(&bytes.Buffer{})
+	arr := ints(1, 2)
+	call(t, r, "push", arr, str("x"))
+	call(t, r, "rest", arr)
+	if arr.Inspect() != "[1, 2]" {
+		t.Errorf("argument was modified. got=%s", arr.Inspect())
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var out bytes.Buffer
+	r := NewDefaultRegistry(&out)
+
+	if result := call(t, r, "puts", str("a"), ints(1)); result != object.NULL {
+		t.Errorf("puts returned %s", result.Inspect())
+	}
+	call(t, r, "puts")
+	call(t, r, "print", str("b"), integer(2))
+
+	expected := "a\n[1]\nb 2"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestArgumentErrors(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []object.Object
+		expectedCode    diagnostic.Code
+		expectedMessage string
+	}{
+		{"len", []object.Object{}, diagnostic.WrongArgumentCount, "wrong number of arguments to len: want=1, got=0"},
+		{"len", []object.Object{str("a"), str("b")}, diagnostic.WrongArgumentCount, "wrong number of arguments to len: want=1, got=2"},
+		{"range", []object.Object{}, diagnostic.WrongArgumentCount, "wrong number of arguments to range: want=1..3, got=0"},
+		{"len", []object.Object{integer(1)}, diagnostic.ArgumentType, "argument 1 to len must be STRING, ARRAY or HASH, got INTEGER"},
+		{"first", []object.Object{str("a")}, diagnostic.ArgumentType, "argument 1 to first must be ARRAY, got STRING"},
+		{"push", []object.Object{object.NULL, str("a")}, diagnostic.ArgumentType, "argument 1 to push must be ARRAY, got NULL"},
+		{"range", []object.Object{integer(1), str("a")}, diagnostic.ArgumentType, "argument 2 to range must be INTEGER, got STRING"},
+		{"int", []object.Object{str("abc")}, diagnostic.InvalidArgument, `cannot convert "abc" to INTEGER`},
+		{"int", []object.Object{ints()}, diagnostic.ArgumentType, "argument 1 to int must be INTEGER, FLOAT, BOOLEAN or STRING, got ARRAY"},
+		{"range", []object.Object{integer(0), integer(1), integer(0)}, diagnostic.InvalidArgument, "range step must not be zero"},
+	}
+
+	r := NewDefaultRegistry(&bytes.Buffer{})
+	for _, tt := range tests {
+		result := call(t, r, tt.name, tt.args...)
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.name, result, result)
+			continue
+		}
+		if errObj.Code != tt.expectedCode {
+			t.Errorf("%s: wrong code. expected=%s, got=%s", tt.name, tt.expectedCode, errObj.Code)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong message. expected=%q, got=%q", tt.name, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestRegisterCustomBuiltin(t *testing.T) {
+	r := NewRegistry(&bytes.Buffer{})
+	r.Register(&Builtin{
+		Name:    "repeat",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Params:  []object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ},
+		Fn: func(ctx *Context, args []object.Object) object.Object {
+			s := args[0].(*object.String).Value
+			out := ""
+			for i := int64(0); i < args[1].(*object.Integer).Value; i++ {
+				out += s
+			}
+			return str(out)
+		},
+	})
+
+	if _, ok := r.Lookup("len"); ok {
+		t.Errorf("NewRegistry should be empty")
+	}
+	if result := call(t, r, "repeat", str("ab"), integer(3)); result.Inspect() != "ababab" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	result := call(t, r, "repeat", integer(3), str("ab"))
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "argument 1 to repeat must be STRING, got INTEGER" {
+		t.Errorf("argument types not checked. got=%s", result.Inspect())
+	}
+
+	// 可变参数按最后一个声明的类型检查
+	r.Register(&Builtin{
+		Name:    "sum",
+		MaxArgs: Variadic,
+		Params:  []object.ObjectType{object.INTEGER_OBJ},
+		Fn: func(ctx *Context, args []object.Object) object.Object {
+			var total int64
+			for _, arg := range args {
+				total += arg.(*object.Integer).Value
+			}
+			return integer(total)
+		},
+	})
+	if result := call(t, r, "sum", ints(1, 2, 3).Elements...); result.Inspect() != "6" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	result = call(t, r, "sum", integer(1), str("2"))
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "argument 2 to sum must be INTEGER, got STRING" {
+		t.Errorf("variadic argument types not checked. got=%s", result.Inspect())
+	}
+}
@@ -0,0 +1,157 @@
+package builtins
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go_interp/interp/object"
+)
+
+func standardBuiltins() []*Builtin {
+	return []*Builtin{
+		{Name: "len", MinArgs: 1, MaxArgs: 1, Fn: builtinLen},
+		{Name: "puts", MinArgs: 0, MaxArgs: Variadic, Fn: builtinPuts},
+		{Name: "print", MinArgs: 0, MaxArgs: Variadic, Fn: builtinPrint},
+		{Name: "type", MinArgs: 1, MaxArgs: 1, Fn: builtinType},
+		{Name: "str", MinArgs: 1, MaxArgs: 1, Fn: builtinStr},
+		{Name: "int", MinArgs: 1, MaxArgs: 1, Fn: builtinInt},
+		{Name: "first", MinArgs: 1, MaxArgs: 1, Params: []object.ObjectType{object.ARRAY_OBJ}, Fn: builtinFirst},
+		{Name: "last", MinArgs: 1, MaxArgs: 1, Params: []object.ObjectType{object.ARRAY_OBJ}, Fn: builtinLast},
+		{Name: "rest", MinArgs: 1, MaxArgs: 1, Params: []object.ObjectType{object.ARRAY_OBJ}, Fn: builtinRest},
+		{Name: "push", MinArgs: 2, MaxArgs: 2, Params: []object.ObjectType{object.ARRAY_OBJ, Any}, Fn: builtinPush},
+		{Name: "range", MinArgs: 1, MaxArgs: 3, Params: []object.ObjectType{object.INTEGER_OBJ}, Fn: builtinRange},
+	}
+}
+
+// builtinLen 字符串的长度按字符计算
+func builtinLen(ctx *Context, args []object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(arg.Len())}
+	}
+	return ArgumentError("len", 0, args[0], object.STRING_OBJ, object.ARRAY_OBJ, object.HASH_OBJ)
+}
+
+// builtinPuts 每个参数输出一行
+func builtinPuts(ctx *Context, args []object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(ctx.Out, arg.Inspect())
+	}
+	return object.NULL
+}
+
+// builtinPrint 参数之间以空格分隔, 不换行
+func builtinPrint(ctx *Context, args []object.Object) object.Object {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Inspect()
+	}
+	fmt.Fprint(ctx.Out, strings.Join(parts, " "))
+	return object.NULL
+}
+
+func builtinType(ctx *Context, args []object.Object) object.Object {
+	return &object.String{Value: string(args[0].Type())}
+}
+
+func builtinStr(ctx *Context, args []object.Object) object.Object {
+	if str, ok := args[0].(*object.String); ok {
+		return str
+	}
+	return &object.String{Value: args[0].Inspect()}
+}
+
+// builtinInt 浮点数向零取整, 字符串按十进制解析
+func builtinInt(ctx *Context, args []object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.Boolean:
+		if arg.Value {
+			return &object.Integer{Value: 1}
+		}
+		return &object.Integer{Value: 0}
+	case *object.String:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return Errorf("cannot convert %q to INTEGER", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	}
+	return ArgumentError("int", 0, args[0], object.INTEGER_OBJ, object.FLOAT_OBJ, object.BOOLEAN_OBJ, object.STRING_OBJ)
+}
+
+// builtinFirst 空数组返回null
+func builtinFirst(ctx *Context, args []object.Object) object.Object {
+	arr := args[0].(*object.Array)
+	if len(arr.Elements) == 0 {
+		return object.NULL
+	}
+	return arr.Elements[0]
+}
+
+func builtinLast(ctx *Context, args []object.Object) object.Object {
+	arr := args[0].(*object.Array)
+	if len(arr.Elements) == 0 {
+		return object.NULL
+	}
+	return arr.Elements[len(arr.Elements)-1]
+}
+
+// builtinRest 返回除第一个元素外的新数组, 原数组不变
+func builtinRest(ctx *Context, args []object.Object) object.Object {
+	arr := args[0].(*object.Array)
+	if len(arr.Elements) == 0 {
+		return object.NULL
+	}
+	elements := make([]object.Object, len(arr.Elements)-1)
+	copy(elements, arr.Elements[1:])
+	return &object.Array{Elements: elements}
+}
+
+// builtinPush 返回追加元素后的新数组, 原数组不变
+func builtinPush(ctx *Context, args []object.Object) object.Object {
+	arr := args[0].(*object.Array)
+	elements := make([]object.Object, len(arr.Elements)+1)
+	copy(elements, arr.Elements)
+	elements[len(arr.Elements)] = args[1]
+	return &object.Array{Elements: elements}
+}
+
+// builtinRange range(end), range(start, end)或range(start, end, step), 不包含end
+func builtinRange(ctx *Context, args []object.Object) object.Object {
+	var start, end, step int64 = 0, 0, 1
+	switch len(args) {
+	case 1:
+		end = args[0].(*object.Integer).Value
+	case 2:
+		start = args[0].(*object.Integer).Value
+		end = args[1].(*object.Integer).Value
+	case 3:
+		start = args[0].(*object.Integer).Value
+		end = args[1].(*object.Integer).Value
+		step = args[2].(*object.Integer).Value
+	}
+	if step == 0 {
+		return Errorf("range step must not be zero")
+	}
+
+	elements := []object.Object{}
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		elements = append(elements, &object.Integer{Value: i})
+		// 下一个值溢出时一定已经越过end, 不能让i回绕后继续循环
+		if (step > 0 && i > math.MaxInt64-step) || (step < 0 && i < math.MinInt64-step) {
+			break
+		}
+	}
+	return &object.Array{Elements: elements}
+}
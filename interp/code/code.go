@@ -0,0 +1,271 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions 字节码指令序列, 每条指令由一个字节的操作码和若干定长操作数组成
+type Instructions []byte
+
+// String 以反汇编的形式列出全部指令, 每行一条, 如"0003 OpConstant 1"
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d", len(operands), len(def.OperandWidths))
+	}
+
+	var out bytes.Buffer
+	out.WriteString(def.Name)
+	for _, operand := range operands {
+		fmt.Fprintf(&out, " %d", operand)
+	}
+	return out.String()
+}
+
+type Opcode byte
+
+// 操作码的数值会写入字节码文件, 增删或调整顺序后需要增加compiler.FormatVersion
+const (
+	// OpConstant 将常量池中的常量压栈
+	OpConstant Opcode = iota
+	// OpPop 弹出栈顶的值, 用于丢弃表达式语句的结果
+	OpPop
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	// 二元运算: 弹出右、左两个操作数, 压入运算结果
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShl
+	OpShr
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpLessEqual
+	OpGreaterThan
+	OpGreaterEqual
+
+	// 一元运算
+	OpMinus
+	OpBang
+	OpBitNot
+
+	// OpJump 无条件跳转到操作数给出的绝对位置
+	OpJump
+	// OpJumpNotTruthy 弹出栈顶的值, 为假时跳转
+	OpJumpNotTruthy
+
+	// OpGetGlobal 读取全局变量; 尚未赋值时查找同名的内置函数, 也没有时为运行时错误
+	OpGetGlobal
+	// OpSetGlobal 弹出栈顶的值并绑定到全局变量(let)
+	OpSetGlobal
+	// OpAssignGlobal 与OpSetGlobal相同, 但全局变量必须已经绑定过(赋值表达式)
+	OpAssignGlobal
+	// OpCheckGlobal 检查全局变量已经绑定过, 否则为对未声明变量赋值的错误; 用于复合赋值读取目标之前
+	OpCheckGlobal
+	OpGetLocal
+	OpSetLocal
+	// OpGetFree 读取当前闭包捕获的外层变量
+	OpGetFree
+	OpSetFree
+
+	// OpArray 弹出操作数个元素, 组成数组
+	OpArray
+	// OpHash 弹出操作数个值, 依次为键、值、键、值...
+	OpHash
+	// OpHashKey 检查栈顶的值能否作为哈希表的键, 不弹出
+	OpHashKey
+	OpIndex
+	// OpSlice 操作数的第0位表示栈上有下界, 第1位表示有上界
+	OpSlice
+	// OpSetIndex 弹出值、下标和容器, 赋值后压入值; 操作数不为0时是复合赋值的二元运算操作码加1
+	OpSetIndex
+	// OpInterpolate 弹出操作数个值, 按Inspect()拼接为字符串
+	OpInterpolate
+
+	// OpCall 调用栈上的函数, 操作数为参数个数, 参数在函数之上
+	OpCall
+	// OpReturnValue 返回栈顶的值
+	OpReturnValue
+	// OpReturn 没有值的返回, 函数调用的结果为null
+	OpReturn
+	// OpClosure 以常量池中的函数创建闭包, 第二个操作数为捕获的变量个数
+	OpClosure
+	// OpCloseUpvalues 局部变量的作用域结束, 被闭包捕获的、下标不小于操作数的局部变量从栈上移出
+	OpCloseUpvalues
+
+	// OpIterInit 弹出可遍历的值, 压入遍历它的迭代器
+	OpIterInit
+	// OpIterNext 迭代器位于栈顶, 还有元素时压入下一个元素, 否则跳转到操作数给出的位置
+	OpIterNext
+)
+
+// Definition 操作码的名称和各操作数的字节宽度
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShl:          {"OpShl", []int{}},
+	OpShr:          {"OpShr", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpBitNot: {"OpBitNot", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{2}},
+	OpSetGlobal:    {"OpSetGlobal", []int{2}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpCheckGlobal:  {"OpCheckGlobal", []int{2}},
+	OpGetLocal:     {"OpGetLocal", []int{1}},
+	OpSetLocal:     {"OpSetLocal", []int{1}},
+	OpGetFree:      {"OpGetFree", []int{1}},
+	OpSetFree:      {"OpSetFree", []int{1}},
+
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{2}},
+	OpHashKey:     {"OpHashKey", []int{}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpSlice:       {"OpSlice", []int{1}},
+	OpSetIndex:    {"OpSetIndex", []int{1}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpCall:          {"OpCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpReturn:        {"OpReturn", []int{}},
+	OpClosure:       {"OpClosure", []int{2, 1}},
+	OpCloseUpvalues: {"OpCloseUpvalues", []int{1}},
+
+	OpIterInit: {"OpIterInit", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}},
+}
+
+// 切片指令的操作数标志
+const (
+	SliceLow  = 1 << 0
+	SliceHigh = 1 << 1
+)
+
+// Lookup 返回操作码的定义, 未定义的操作码返回错误
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make 编码一条指令; 操作数按大端序写入, 未定义的操作码返回空指令
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		if i >= len(def.OperandWidths) {
+			break
+		}
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+// ReadOperands 解码Make写入的操作数, 返回操作数和读取的字节数
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// MaxOperand 返回宽度为width字节的操作数所能表示的最大值
+func MaxOperand(width int) int {
+	return 1<<(8*width) - 1
+}
@@ -0,0 +1,40 @@
+package code
+
+import (
+	"sort"
+
+	"go_interp/model/token"
+)
+
+// LineInfo 从Offset开始的指令由Span处的源码编译而来
+type LineInfo struct {
+	Offset int
+	Span   token.Span
+}
+
+// LineTable 调试信息, 按Offset升序排列; 运行时错误据此找到对应的源码位置
+type LineTable []LineInfo
+
+// Lookup 返回位于offset的指令对应的源码区间
+func (t LineTable) Lookup(offset int) (token.Span, bool) {
+	i := sort.Search(len(t), func(i int) bool { return t[i].Offset > offset })
+	if i == 0 {
+		return token.Span{}, false
+	}
+	return t[i-1].Span, true
+}
+
+// Add 记录从offset开始的指令的源码区间, 与上一条相同时不重复记录
+func (t *LineTable) Add(offset int, span token.Span) {
+	if n := len(*t); n > 0 {
+		last := &(*t)[n-1]
+		if last.Span == span {
+			return
+		}
+		if last.Offset == offset {
+			last.Span = span
+			return
+		}
+	}
+	*t = append(*t, LineInfo{Offset: offset, Span: span})
+}
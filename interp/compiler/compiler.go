@@ -0,0 +1,600 @@
+package compiler
+
+import (
+	"fmt"
+
+	"go_interp/interp/ast"
+	"go_interp/interp/code"
+	"go_interp/interp/object"
+	"go_interp/model/token"
+)
+
+// Bytecode 编译的结果. 顶层程序同样编译为一个函数, 其局部变量是顶层循环体中的变量;
+// 程序的值是最后一条表达式语句的值, 与求值器一致
+type Bytecode struct {
+	Main      *object.CompiledFunction
+	Constants []object.Object
+	// Globals 全局变量的名字, 下标即OpGetGlobal等指令的操作数, 用于运行时的错误信息
+	Globals []string
+}
+
+// CompilationScope 正在编译的函数体
+type CompilationScope struct {
+	instructions code.Instructions
+	lines        code.LineTable
+	// loops 当前所在的循环, 最内层的在最后
+	loops []*loop
+	// pending 外层表达式已经压栈、尚未使用的值的个数, 如编译右操作数时栈上的左操作数
+	pending int
+}
+
+// loop 记录循环中需要回填的跳转指令
+type loop struct {
+	breaks    []int
+	continues []int
+	// depth 进入循环体时的pending; break和continue出现在表达式中时,
+	// 跳转前弹出多出的值, 使栈回到进入循环体时的状态
+	depth int
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// node 正在编译的节点, 生成的指令以它的区间作为调试信息
+	node ast.Node
+	// err 操作数超出范围等错误, 编译结束时返回
+	err error
+}
+
+func New() *Compiler {
+	return &Compiler{
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// Compile 编译整个程序; program中不能有语法错误
+func Compile(program *ast.Program) (*Bytecode, error) {
+	c := New()
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+	return c.Bytecode(), nil
+}
+
+// Compile 编译node; 通常为*ast.Program, 编译其他节点时生成的指令追加在当前函数体中
+func (c *Compiler) Compile(node ast.Node) error {
+	c.compile(node)
+	return c.err
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: c.currentInstructions(),
+			NumLocals:    c.symbolTable.NumLocals(),
+			Lines:        c.scopes[c.scopeIndex].lines,
+		},
+		Constants: c.constants,
+		Globals:   c.symbolTable.Globals(),
+	}
+}
+
+func (c *Compiler) compile(node ast.Node) {
+	if node == nil || c.err != nil {
+		if node == nil {
+			// 与求值器一致, 缺失的表达式视为null
+			c.emit(code.OpNull)
+		}
+		return
+	}
+
+	prev := c.node
+	c.node = node
+	defer func() { c.node = prev }()
+
+	switch node := node.(type) {
+	// 语句
+	case *ast.Program:
+		c.compileBody(node.Statements)
+	case *ast.ExpressionStatement:
+		c.compile(node.Expression)
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			c.compile(stmt)
+		}
+	case *ast.LetStatement:
+		c.compileLetStatement(node)
+	case *ast.ReturnStatement:
+		c.compile(node.Value)
+		c.emit(code.OpReturnValue)
+	case *ast.WhileStatement:
+		c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		c.compileForStatement(node)
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		c.popPending(l)
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		c.popPending(l)
+		l.continues = append(l.continues, c.emit(code.OpJump, 9999))
+
+	// 表达式
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.InterpolatedString:
+		c.compileOperands(node.Parts...)
+		c.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.Identifier:
+		c.loadSymbol(c.resolve(node.Value))
+	case *ast.PrefixExpression:
+		c.compile(node.Right)
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
+		default:
+			c.fail("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		c.compileInfixExpression(node)
+	case *ast.IfExpression:
+		c.compileIfExpression(node)
+	case *ast.FunctionLiteral:
+		c.compileFunctionLiteral(node, "")
+	case *ast.CallExpression:
+		c.compileOperands(append([]ast.Expression{node.Function}, node.Arguments...)...)
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ArrayLiteral:
+		c.compileOperands(node.Elements...)
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			c.compile(pair.Key)
+			// 字面量一定可以作为键; 其余的键在求值值之前检查, 错误位置与求值器一致
+			switch pair.Key.(type) {
+			case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+			default:
+				c.withNode(pair.Key, func() { c.emit(code.OpHashKey) })
+			}
+			c.hold(1)
+			c.compile(pair.Value)
+			c.hold(1)
+		}
+		c.hold(-len(node.Pairs) * 2)
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		c.compileOperands(node.Left, node.Index)
+		c.emit(code.OpIndex)
+	case *ast.SliceExpression:
+		operands := []ast.Expression{node.Left}
+		flags := 0
+		if node.Low != nil {
+			operands = append(operands, node.Low)
+			flags |= code.SliceLow
+		}
+		if node.High != nil {
+			operands = append(operands, node.High)
+			flags |= code.SliceHigh
+		}
+		c.compileOperands(operands...)
+		c.emit(code.OpSlice, flags)
+	case *ast.AssignExpression:
+		c.compileAssignExpression(node)
+	default:
+		c.fail("cannot compile %T", node)
+	}
+}
+
+// compileStatements 依次编译语句; 最后一条是表达式语句时保留它的值在栈上并返回true
+func (c *Compiler) compileStatements(stmts []ast.Statement) bool {
+	for i, stmt := range stmts {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && i == len(stmts)-1 {
+			c.compile(es.Expression)
+			return true
+		}
+		c.compile(stmt)
+	}
+	return false
+}
+
+// compileBody 编译函数体或顶层程序, 返回最后一条表达式语句的值; 没有值时为OpReturn
+func (c *Compiler) compileBody(stmts []ast.Statement) {
+	if c.compileStatements(stmts) {
+		c.emit(code.OpReturnValue)
+		return
+	}
+	if n := len(stmts); n > 0 {
+		if _, ok := stmts[n-1].(*ast.ReturnStatement); ok {
+			return
+		}
+	}
+	c.emit(code.OpReturn)
+}
+
+// compileBlockValue 编译作为值使用的块(if的分支), 块的值为最后一条表达式语句的值, 否则为null
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) {
+	if !c.compileStatements(block.Statements) {
+		c.emit(code.OpNull)
+	}
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) {
+	// 函数先绑定名字再编译函数体, 使函数体可以通过名字递归调用自身
+	if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol := c.symbolTable.Define(node.Name.Value)
+		c.withNode(fn, func() { c.compileFunctionLiteral(fn, node.Name.Value) })
+		c.storeSymbol(symbol, code.OpSetGlobal)
+		return
+	}
+
+	c.compile(node.Value)
+	symbol := c.symbolTable.Define(node.Name.Value)
+	c.storeSymbol(symbol, code.OpSetGlobal)
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) {
+	switch node.Operator {
+	case "&&":
+		// 与求值器一致, 逻辑运算的结果总是布尔值; 两次OpBang将右操作数转换为布尔值
+		c.compile(node.Left)
+		jumpFalse := c.emit(code.OpJumpNotTruthy, 9999)
+		c.compile(node.Right)
+		c.emit(code.OpBang)
+		c.emit(code.OpBang)
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpFalse, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return
+	case "||":
+		c.compile(node.Left)
+		jumpRight := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpRight, len(c.currentInstructions()))
+		c.compile(node.Right)
+		c.emit(code.OpBang)
+		c.emit(code.OpBang)
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return
+	}
+
+	op, ok := infixOpcodes[node.Operator]
+	if !ok {
+		c.fail("unknown operator %s", node.Operator)
+		return
+	}
+	c.compileOperands(node.Left, node.Right)
+	c.emit(op)
+}
+
+// compileOperands 依次编译exps, 它们的值留在栈上由之后的一条指令使用.
+// 编译后面的表达式时, 前面的值计入pending
+func (c *Compiler) compileOperands(exps ...ast.Expression) {
+	for _, exp := range exps {
+		c.compile(exp)
+		c.hold(1)
+	}
+	c.hold(-len(exps))
+}
+
+// hold 调整当前函数体的pending; n为负数时表示这些值已被使用
+func (c *Compiler) hold(n int) {
+	c.scopes[c.scopeIndex].pending += n
+}
+
+// popPending 弹出外层表达式留在栈上的值, 回到进入循环l的循环体时的状态
+func (c *Compiler) popPending(l *loop) {
+	for i := l.depth; i < c.scopes[c.scopeIndex].pending; i++ {
+		c.emit(code.OpPop)
+	}
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShl,
+	">>": code.OpShr,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) {
+	c.compile(node.Condition)
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.compileBlockValue(node.Consequence)
+	jump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthy, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		c.compileBlockValue(node.Alternative)
+	}
+	c.changeOperand(jump, len(c.currentInstructions()))
+}
+
+// compileAssignExpression 赋值表达式的值为赋给目标的值, 赋值之后重新读取目标压栈
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) {
+	var op code.Opcode
+	compound := node.Operator != "="
+	if compound {
+		var ok bool
+		if op, ok = infixOpcodes[node.Operator[:len(node.Operator)-1]]; !ok {
+			c.fail("unknown operator %s", node.Operator)
+			return
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		// 对未定义的名字赋值时运行时报告未声明的错误, 除非它之后在顶层绑定;
+		// 内置函数不能被赋值, 同名的全局变量没有绑定时同样报错
+		symbol := c.resolve(target.Value)
+		if compound {
+			c.withNode(target, func() {
+				// 读取之前检查目标已经绑定, 否则读取时会报告标识符不存在, 或读到同名的内置函数
+				if symbol.Scope == GlobalScope {
+					c.emit(code.OpCheckGlobal, symbol.Index)
+				}
+				c.loadSymbol(symbol)
+			})
+			c.hold(1)
+			c.compile(node.Value)
+			c.hold(-1)
+			c.emit(op)
+		} else {
+			c.compile(node.Value)
+		}
+		c.withNode(target, func() { c.storeSymbol(symbol, code.OpAssignGlobal) })
+		c.loadSymbol(symbol)
+
+	case *ast.IndexExpression:
+		c.compileOperands(target.Left, target.Index, node.Value)
+		operand := 0
+		if compound {
+			operand = int(op) + 1
+		}
+		c.emit(code.OpSetIndex, operand)
+
+	default:
+		c.fail("cannot assign to %s", node.Target.String())
+	}
+}
+
+// withNode 以node的区间作为调试信息执行f
+func (c *Compiler) withNode(node ast.Node, f func()) {
+	prev := c.node
+	c.node = node
+	f()
+	c.node = prev
+}
+
+// compileWhileStatement 条件在循环之外的作用域中求值, 循环体是新的作用域
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) {
+	start := len(c.currentInstructions())
+	c.compile(node.Condition)
+	jumpExit := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.symbolTable.PushBlock()
+	c.compileLoopBody(node.Body, start)
+	c.symbolTable.PopBlock()
+
+	c.changeOperand(jumpExit, len(c.currentInstructions()))
+}
+
+// compileForStatement 迭代器在循环期间留在栈上, 循环结束后弹出
+func (c *Compiler) compileForStatement(node *ast.ForStatement) {
+	c.compile(node.Iterable)
+	// 不可遍历时, 错误位置为被遍历的表达式
+	c.withNode(node.Iterable, func() { c.emit(code.OpIterInit) })
+
+	start := len(c.currentInstructions())
+	next := c.emit(code.OpIterNext, 9999)
+
+	c.symbolTable.PushBlock()
+	c.storeSymbol(c.symbolTable.Define(node.Variable.Value), code.OpSetGlobal)
+	c.hold(1)
+	c.compileLoopBody(node.Body, start)
+	c.hold(-1)
+	c.symbolTable.PopBlock()
+
+	c.changeOperand(next, len(c.currentInstructions()))
+	c.emit(code.OpPop)
+}
+
+// compileLoopBody 编译循环体及回到start的跳转. 每次循环结束时, 被闭包捕获的循环体中的变量
+// 从栈上移出, 使每次循环的闭包捕获各自的变量; break跳出循环前同样如此
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, start int) {
+	scope := &c.scopes[c.scopeIndex]
+	l := &loop{depth: scope.pending}
+	scope.loops = append(scope.loops, l)
+	c.compile(body)
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	first, count := c.symbolTable.BlockLocals()
+	for _, pos := range l.continues {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if count > 0 {
+		c.emit(code.OpCloseUpvalues, first)
+	}
+	c.emit(code.OpJump, start)
+
+	// break跳过回到开头的跳转, 之后的位置即循环的出口
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if count > 0 && len(l.breaks) > 0 {
+		c.emit(code.OpCloseUpvalues, first)
+	}
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) {
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	c.compileBody(node.Body.Statements)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumLocals()
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+
+	captures := make([]object.Capture, len(freeSymbols))
+	for i, s := range freeSymbols {
+		captures[i] = object.Capture{Local: s.Scope == LocalScope, Index: s.Index}
+	}
+
+	fn := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Captures:      captures,
+		Lines:         lines,
+		Source:        node.String(),
+	}
+	c.emit(code.OpClosure, c.addConstant(fn), len(captures))
+}
+
+// resolve 查找标识符. 没有绑定时视为之后才定义的全局变量; 内置函数同样如此,
+// 运行时全局变量没有绑定才使用内置函数, 与求值器一致, 绑定可以遮蔽同名的内置函数
+func (c *Compiler) resolve(name string) Symbol {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		return symbol
+	}
+	return c.symbolTable.DefineGlobal(name)
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+// storeSymbol 弹出栈顶的值存入s; global为存入全局变量时使用的指令
+func (c *Compiler) storeSymbol(s Symbol, global code.Opcode) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(global, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		// 语法分析已经拒绝循环之外的break和continue
+		c.fail("break or continue outside of a loop")
+		return &loop{}
+	}
+	return loops[len(loops)-1]
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit 追加一条指令并返回它的位置
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+
+	scope := &c.scopes[c.scopeIndex]
+	pos := len(scope.instructions)
+	if c.node != nil {
+		scope.lines.Add(pos, token.Span{Start: c.node.Pos(), End: c.node.End()})
+	}
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+	return pos
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+// checkOperands 操作数超出其宽度时报告编译错误, 否则code.Make会截断它
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return
+	}
+	for i, operand := range operands {
+		if i < len(def.OperandWidths) && (operand < 0 || operand > code.MaxOperand(def.OperandWidths[i])) {
+			c.fail("operand %d of %s out of range", operand, def.Name)
+		}
+	}
+}
+
+// changeOperand 回填跳转指令的目标; 函数体过长时目标可能超出操作数的范围
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[opPos])
+	c.checkOperands(op, []int{operand})
+	copy(ins[opPos:], code.Make(op, operand))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
+
+func (c *Compiler) fail(format string, a ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf(format, a...)
+	}
+}
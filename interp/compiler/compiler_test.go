@@ -0,0 +1,880 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go_interp/interp/ast"
+	"go_interp/interp/code"
+	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+// function 常量池中编译后的函数, 按指令和捕获的变量比较
+type function struct {
+	instructions []code.Instructions
+	captures     []object.Capture
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "2 % 3 << 1",
+			expectedConstants: []interface{}{2, 3, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpShl),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1.5",
+			expectedConstants: []interface{}{1.5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "~1 ^ 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpBitNot),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "!(true != false)",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpFalse),
+				code.Make(code.OpNotEqual),
+				code.Make(code.OpBang),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpBang),
+				// 0006
+				code.Make(code.OpBang),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpFalse),
+				// 0011
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 8),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJump, 11),
+				// 0008
+				code.Make(code.OpFalse),
+				// 0009
+				code.Make(code.OpBang),
+				// 0010
+				code.Make(code.OpBang),
+				// 0011
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { 10 } else { let x = 20; }",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 17),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpSetGlobal, 0),
+				// 0016
+				code.Make(code.OpNull),
+				// 0017
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			// 同一作用域中重复定义时复用原来的位置
+			input:             "let one = 1; let one = one + 1; one",
+			expectedConstants: []interface{}{1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"mon" + "key"`,
+			expectedConstants: []interface{}{"mon", "key"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `"a${1}b"`,
+			expectedConstants: []interface{}{"a", 1, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpInterpolate, 3),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestArraysAndHashes(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[]",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "[1, 2 + 3]",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `{1: "a", 2: "b"}`,
+			expectedConstants: []interface{}{1, "a", 2, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `{1 + 1: 2}`,
+			expectedConstants: []interface{}{1, 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpHashKey),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "[1, 2][-1]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMinus),
+				code.Make(code.OpIndex),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `"abc"[1:]; "abc"[:]`,
+			expectedConstants: []interface{}{"abc", 1, "abc"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSlice, code.SliceLow),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSlice, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { return 5 + 10 }",
+			expectedConstants: []interface{}{
+				5,
+				10,
+				function{instructions: []code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				function{instructions: []code.Instructions{
+					code.Make(code.OpReturn),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "let f = fn(a, b) { let c = a + b; }; f(1, 2)",
+			expectedConstants: []interface{}{
+				function{instructions: []code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpReturn),
+				}},
+				1,
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 函数体中引用之后才定义的全局变量
+			input: "let f = fn() { g() }; let g = fn() { 1 };",
+			expectedConstants: []interface{}{
+				function{instructions: []code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				}},
+				1,
+				function{instructions: []code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			// 内置函数与未定义的名字一样编译为全局变量, 运行时没有绑定才使用内置函数
+			input:             "len([]); push([], 1);",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 绑定遮蔽同名的内置函数
+			input:             "let len = 1; len",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				function{
+					instructions: []code.Instructions{
+						code.Make(code.OpGetFree, 0),
+						code.Make(code.OpGetLocal, 0),
+						code.Make(code.OpAdd),
+						code.Make(code.OpReturnValue),
+					},
+					captures: []object.Capture{{Local: true, Index: 0}},
+				},
+				function{instructions: []code.Instructions{
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// 最内层的函数通过中间一层已捕获的变量引用a
+			input: "fn(a) { fn(b) { fn(c) { a + b + c } } }",
+			expectedConstants: []interface{}{
+				function{
+					instructions: []code.Instructions{
+						code.Make(code.OpGetFree, 0),
+						code.Make(code.OpGetFree, 1),
+						code.Make(code.OpAdd),
+						code.Make(code.OpGetLocal, 0),
+						code.Make(code.OpAdd),
+						code.Make(code.OpReturnValue),
+					},
+					captures: []object.Capture{{Local: false, Index: 0}, {Local: true, Index: 0}},
+				},
+				function{
+					instructions: []code.Instructions{
+						code.Make(code.OpClosure, 0, 2),
+						code.Make(code.OpReturnValue),
+					},
+					captures: []object.Capture{{Local: true, Index: 0}},
+				},
+				function{instructions: []code.Instructions{
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "let wrapper = fn() { let countDown = fn(x) { countDown(x - 1); }; countDown(1); }; wrapper();",
+			expectedConstants: []interface{}{
+				1,
+				function{
+					instructions: []code.Instructions{
+						code.Make(code.OpGetFree, 0),
+						code.Make(code.OpGetLocal, 0),
+						code.Make(code.OpConstant, 0),
+						code.Make(code.OpSub),
+						code.Make(code.OpCall, 1),
+						code.Make(code.OpReturnValue),
+					},
+					captures: []object.Capture{{Local: true, Index: 0}},
+				},
+				1,
+				function{instructions: []code.Instructions{
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x = 2; x += 3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpCheckGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { let x = 0; fn() { x *= 2 } }",
+			expectedConstants: []interface{}{
+				0,
+				2,
+				function{
+					instructions: []code.Instructions{
+						code.Make(code.OpGetFree, 0),
+						code.Make(code.OpConstant, 1),
+						code.Make(code.OpMul),
+						code.Make(code.OpSetFree, 0),
+						code.Make(code.OpGetFree, 0),
+						code.Make(code.OpReturnValue),
+					},
+					captures: []object.Capture{{Local: true, Index: 0}},
+				},
+				function{instructions: []code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] = 2; a[0] -= 1",
+			expectedConstants: []interface{}{1, 0, 2, 0, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpSetIndex, int(code.OpSub)+1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { 1 }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpPop),
+				// 0008
+				code.Make(code.OpJump, 0),
+				// 0011
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "while (true) { break; continue; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0004
+				code.Make(code.OpJump, 13),
+				// 0007
+				code.Make(code.OpJump, 10),
+				// 0010
+				code.Make(code.OpJump, 0),
+				// 0013
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			// 顶层循环体中的变量是顶层程序的局部变量
+			input:             "while (false) { let y = 1; }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetLocal, 0),
+				// 0009
+				code.Make(code.OpCloseUpvalues, 0),
+				// 0011
+				code.Make(code.OpJump, 0),
+				// 0014
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "for (x in [1, 2]) { x }",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpConstant, 1),
+				// 0006
+				code.Make(code.OpArray, 2),
+				// 0009
+				code.Make(code.OpIterInit),
+				// 0010
+				code.Make(code.OpIterNext, 23),
+				// 0013
+				code.Make(code.OpSetLocal, 0),
+				// 0015
+				code.Make(code.OpGetLocal, 0),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpCloseUpvalues, 0),
+				// 0020
+				code.Make(code.OpJump, 10),
+				// 0023
+				code.Make(code.OpPop),
+				// 0024
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "for (x in []) { break }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpArray, 0),
+				// 0003
+				code.Make(code.OpIterInit),
+				// 0004
+				code.Make(code.OpIterNext, 19),
+				// 0007
+				code.Make(code.OpSetLocal, 0),
+				// 0009
+				code.Make(code.OpJump, 17),
+				// 0012
+				code.Make(code.OpCloseUpvalues, 0),
+				// 0014
+				code.Make(code.OpJump, 4),
+				// 0017
+				code.Make(code.OpCloseUpvalues, 0),
+				// 0019
+				code.Make(code.OpPop),
+				// 0020
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLineTable(t *testing.T) {
+	program := parse("let x = 1;\nx + y")
+	bytecode, err := Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	// 0000 OpConstant 0; 0003 OpSetGlobal 0; 0006 OpGetGlobal 0; 0009 OpGetGlobal 1; 0012 OpAdd
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "1"},
+		{3, "let x = 1"},
+		{6, "x"},
+		{9, "y"},
+		{12, "x + y"},
+	}
+
+	source := "let x = 1;\nx + y"
+	for _, tt := range tests {
+		span, ok := bytecode.Main.Lines.Lookup(tt.offset)
+		if !ok {
+			t.Errorf("no position for offset %d", tt.offset)
+			continue
+		}
+		got := source[span.Start.Offset:span.End.Offset]
+		if got != tt.expected {
+			t.Errorf("offset %d: source=%q, want %q", tt.offset, got, tt.expected)
+		}
+	}
+
+	if len(bytecode.Globals) != 2 || bytecode.Globals[0] != "x" || bytecode.Globals[1] != "y" {
+		t.Errorf("wrong globals: %v", bytecode.Globals)
+	}
+}
+
+func TestJumpOutOfRange(t *testing.T) {
+	// 每条"1;"编译为4字节, 循环体或分支超过64KiB时跳转目标无法用两个字节表示
+	body := strings.Repeat("1; ", 20000)
+	tests := []string{
+		"while (false) { " + body + "}",
+		"if (true) { " + body + "} else { 2 }",
+		"for (x in []) { " + body + "}",
+	}
+
+	for _, input := range tests {
+		_, err := Compile(parse(input))
+		if err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("%.20q: expected an out of range error, got %v", input, err)
+		}
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		bytecode, err := Compile(program)
+		if err != nil {
+			t.Fatalf("%s: compiler error: %s", tt.input, err)
+		}
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Main.Instructions); err != nil {
+			t.Errorf("%s: testInstructions failed: %s", tt.input, err)
+		}
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Errorf("%s: testConstants failed: %s", tt.input, err)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.Load(input)
+	p := parser.Parse(l)
+	return p.ParseProgram()
+}
+
+// testInstructions 按反汇编的文本比较, 出错时可以直接对照两份指令清单
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if actual.String() != concatted.String() {
+		return fmt.Errorf("wrong instructions.\nwant:\n%s\ngot:\n%s", concatted, actual)
+	}
+	return nil
+}
+
+func testConstants(expected []interface{}, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. got=%d, want=%d", len(actual), len(expected))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return fmt.Errorf("constant %d - want integer %d, got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case float64:
+			float, ok := actual[i].(*object.Float)
+			if !ok || float.Value != constant {
+				return fmt.Errorf("constant %d - want float %g, got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				return fmt.Errorf("constant %d - want string %q, got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case function:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d - not a function: %T", i, actual[i])
+			}
+			if err := testInstructions(constant.instructions, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d - %s", i, err)
+			}
+			if fmt.Sprint(fn.Captures) != fmt.Sprint(constant.captures) && len(fn.Captures)+len(constant.captures) > 0 {
+				return fmt.Errorf("constant %d - wrong captures. want=%v, got=%v", i, constant.captures, fn.Captures)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,464 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"go_interp/interp/code"
+	"go_interp/interp/object"
+	"go_interp/model/token"
+)
+
+// 字节码文件的格式:
+//
+//	magic      4字节 "MKC\x00"
+//	version    uint16, 大端序
+//	sourceHash 32字节, 源码的SHA-256
+//	filename   字符串
+//	sourcePath 字符串
+//	globals    数量, 然后是每个全局变量的名字
+//	constants  数量, 然后是每个常量: 1字节的类型标记和内容
+//	main       顶层程序, 与函数常量的编码相同
+//
+// 数量和整数使用varint编码, 字符串为长度加UTF-8字节. 函数依次编码名字、源码、
+// 局部变量数、参数个数、捕获的变量、指令和行号表; 行号表中的位置不含文件名,
+// 解码时统一设为filename.
+const (
+	Magic = "MKC\x00"
+	// FormatVersion 操作码或编码方式改变时必须增加, 旧版本的文件会被拒绝
+	FormatVersion = 1
+)
+
+// 常量的类型标记
+const (
+	tagInteger  byte = 'i'
+	tagFloat    byte = 'f'
+	tagString   byte = 's'
+	tagFunction byte = 'F'
+)
+
+// ErrFormat 数据不是字节码文件, 或已损坏
+var ErrFormat = errors.New("not a valid bytecode file")
+
+// ErrVersion 字节码文件由不同版本的编译器生成
+var ErrVersion = errors.New("unsupported bytecode version")
+
+// SourceHash 源码的SHA-256, 用于判断字节码是否过期
+type SourceHash [sha256.Size]byte
+
+func HashSource(source string) SourceHash {
+	return sha256.Sum256([]byte(source))
+}
+
+// File 字节码文件的内容
+type File struct {
+	SourceHash SourceHash
+	// Filename 源文件名, 运行时错误的位置中使用
+	Filename string
+	// SourcePath 源文件的路径, 用于检查字节码是否过期; 相对路径相对于字节码文件所在的目录,
+	// 从标准输入编译时为空
+	SourcePath string
+	Bytecode   *Bytecode
+}
+
+// IsBytecode 判断数据是否以字节码文件的魔数开头
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+// Encode 将f编码后写入w
+func Encode(w io.Writer, f *File) error {
+	e := &encoder{}
+	e.buf.WriteString(Magic)
+	var version [2]byte
+	binary.BigEndian.PutUint16(version[:], FormatVersion)
+	e.buf.Write(version[:])
+	e.buf.Write(f.SourceHash[:])
+	e.string(f.Filename)
+	e.string(f.SourcePath)
+
+	e.uvarint(len(f.Bytecode.Globals))
+	for _, name := range f.Bytecode.Globals {
+		e.string(name)
+	}
+	e.uvarint(len(f.Bytecode.Constants))
+	for _, constant := range f.Bytecode.Constants {
+		e.constant(constant)
+	}
+	e.function(f.Bytecode.Main)
+	if e.err != nil {
+		return e.err
+	}
+
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// Decode 读取并解码字节码文件. 魔数不符或数据损坏时返回ErrFormat, 版本不符时返回ErrVersion
+func Decode(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !IsBytecode(data) {
+		return nil, ErrFormat
+	}
+	data = data[len(Magic):]
+	if len(data) < 2 {
+		return nil, ErrFormat
+	}
+	if version := binary.BigEndian.Uint16(data); version != FormatVersion {
+		return nil, fmt.Errorf("%w %d, want %d", ErrVersion, version, FormatVersion)
+	}
+
+	d := &decoder{data: data[2:]}
+	f := &File{Bytecode: &Bytecode{}}
+	copy(f.SourceHash[:], d.bytes(len(f.SourceHash)))
+	f.Filename = d.string()
+	f.SourcePath = d.string()
+
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		f.Bytecode.Globals = append(f.Bytecode.Globals, d.string())
+	}
+	n = d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		f.Bytecode.Constants = append(f.Bytecode.Constants, d.constant(f.Filename))
+	}
+	f.Bytecode.Main = d.function(f.Filename)
+	if d.err == nil && len(d.data) != 0 {
+		d.fail("%d trailing bytes", len(d.data))
+	}
+	if d.err == nil {
+		d.err = verify(f.Bytecode)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return f, nil
+}
+
+// maxLocals 一个函数最多的局部变量数, 受OpGetLocal等指令单字节操作数的限制
+var maxLocals = code.MaxOperand(1) + 1
+
+// verify 检查每个函数的指令都能完整解析, 跳转目标是某条指令的开头, 执行不会越过最后一条指令;
+// 引用的常量、全局变量、局部变量和捕获的变量存在, 局部变量数和参数个数合理.
+// 不检查栈上的值的类型, 它们由编译器保证
+func verify(bytecode *Bytecode) error {
+	check := func(fn *object.CompiledFunction) error {
+		if fn.NumLocals > maxLocals {
+			return fmt.Errorf("%w: invalid local count %d", ErrFormat, fn.NumLocals)
+		}
+		if fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("%w: %d parameters exceed %d locals", ErrFormat, fn.NumParameters, fn.NumLocals)
+		}
+
+		ins := fn.Instructions
+		// starts 每条指令的开头; last 最后一条指令的操作码
+		starts := map[int]bool{}
+		var targets []int
+		var last code.Opcode
+		for i := 0; i < len(ins); {
+			starts[i] = true
+			last = code.Opcode(ins[i])
+			def, err := code.Lookup(ins[i])
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrFormat, err)
+			}
+			width := 0
+			for _, w := range def.OperandWidths {
+				width += w
+			}
+			if i+1+width > len(ins) {
+				return fmt.Errorf("%w: truncated %s at %d", ErrFormat, def.Name, i)
+			}
+			operands, _ := code.ReadOperands(def, ins[i+1:])
+
+			switch code.Opcode(ins[i]) {
+			case code.OpConstant, code.OpClosure:
+				if operands[0] >= len(bytecode.Constants) {
+					return fmt.Errorf("%w: constant %d out of range", ErrFormat, operands[0])
+				}
+				constant := bytecode.Constants[operands[0]]
+				closure, isFunction := constant.(*object.CompiledFunction)
+				if isFunction != (code.Opcode(ins[i]) == code.OpClosure) {
+					return fmt.Errorf("%w: unexpected %s constant for %s", ErrFormat, constant.Type(), def.Name)
+				}
+				if isFunction {
+					if err := checkCaptures(fn, closure, operands[1]); err != nil {
+						return err
+					}
+				}
+			case code.OpGetGlobal, code.OpSetGlobal, code.OpAssignGlobal, code.OpCheckGlobal:
+				if operands[0] >= len(bytecode.Globals) {
+					return fmt.Errorf("%w: global %d out of range", ErrFormat, operands[0])
+				}
+			case code.OpGetLocal, code.OpSetLocal:
+				if operands[0] >= fn.NumLocals {
+					return fmt.Errorf("%w: local %d out of range", ErrFormat, operands[0])
+				}
+			case code.OpCloseUpvalues:
+				// 操作数为第一个移出的局部变量, 可以等于局部变量数
+				if operands[0] > fn.NumLocals {
+					return fmt.Errorf("%w: local %d out of range", ErrFormat, operands[0])
+				}
+			case code.OpGetFree, code.OpSetFree:
+				if operands[0] >= len(fn.Captures) {
+					return fmt.Errorf("%w: free variable %d out of range", ErrFormat, operands[0])
+				}
+			case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+				targets = append(targets, operands[0])
+			}
+			i += 1 + width
+		}
+
+		// 虚拟机执行到指令末尾之前必须返回或跳转
+		switch last {
+		case code.OpReturn, code.OpReturnValue, code.OpJump:
+		default:
+			if len(ins) == 0 {
+				return fmt.Errorf("%w: empty function", ErrFormat)
+			}
+			return fmt.Errorf("%w: function does not end with a return", ErrFormat)
+		}
+		for _, target := range targets {
+			if !starts[target] {
+				return fmt.Errorf("%w: invalid jump target %d", ErrFormat, target)
+			}
+		}
+		return nil
+	}
+
+	if bytecode.Main.NumParameters != 0 {
+		return fmt.Errorf("%w: main program has %d parameters", ErrFormat, bytecode.Main.NumParameters)
+	}
+	if err := check(bytecode.Main); err != nil {
+		return err
+	}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			if err := check(fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkCaptures 检查在函数fn中创建closure的闭包时, closure捕获的变量在fn中存在;
+// n为OpClosure的第二个操作数
+func checkCaptures(fn, closure *object.CompiledFunction, n int) error {
+	if n != len(closure.Captures) {
+		return fmt.Errorf("%w: closure operand %d does not match %d captures", ErrFormat, n, len(closure.Captures))
+	}
+	for _, capture := range closure.Captures {
+		limit := len(fn.Captures)
+		if capture.Local {
+			limit = fn.NumLocals
+		}
+		if capture.Index >= limit {
+			return fmt.Errorf("%w: capture %d out of range", ErrFormat, capture.Index)
+		}
+	}
+	return nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (e *encoder) uvarint(n int) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+func (e *encoder) varint(n int64) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf.Write(b[:binary.PutVarint(b[:], n)])
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(len(s))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) constant(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(tagInteger)
+		e.varint(obj.Value)
+	case *object.Float:
+		e.buf.WriteByte(tagFloat)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(obj.Value))
+		e.buf.Write(b[:])
+	case *object.String:
+		e.buf.WriteByte(tagString)
+		e.string(obj.Value)
+	case *object.CompiledFunction:
+		e.buf.WriteByte(tagFunction)
+		e.function(obj)
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("cannot encode constant of type %s", obj.Type())
+		}
+	}
+}
+
+func (e *encoder) function(fn *object.CompiledFunction) {
+	e.string(fn.Name)
+	e.string(fn.Source)
+	e.uvarint(fn.NumLocals)
+	e.uvarint(fn.NumParameters)
+	e.uvarint(len(fn.Captures))
+	for _, capture := range fn.Captures {
+		local := byte(0)
+		if capture.Local {
+			local = 1
+		}
+		e.buf.WriteByte(local)
+		e.uvarint(capture.Index)
+	}
+	e.uvarint(len(fn.Instructions))
+	e.buf.Write(fn.Instructions)
+	e.uvarint(len(fn.Lines))
+	for _, line := range fn.Lines {
+		e.uvarint(line.Offset)
+		e.pos(line.Span.Start)
+		e.pos(line.Span.End)
+	}
+}
+
+func (e *encoder) pos(p token.Pos) {
+	e.uvarint(p.Offset)
+	e.uvarint(p.Line)
+	e.uvarint(p.Column)
+}
+
+// decoder 按顺序读取data, 出错后所有读取都返回零值, 由调用者最后检查err
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrFormat, fmt.Sprintf(format, a...))
+	}
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data) {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() int {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || n > math.MaxInt32 {
+		d.fail("invalid integer")
+		return 0
+	}
+	d.data = d.data[size:]
+	return int(n)
+}
+
+// count 读取一个数量; 每个元素至少占一个字节, 数量超过剩余的字节数时数据一定已损坏
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > len(d.data) {
+		d.fail("count %d exceeds remaining data", n)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Varint(d.data)
+	if size <= 0 {
+		d.fail("invalid integer")
+		return 0
+	}
+	d.data = d.data[size:]
+	return n
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
+
+func (d *decoder) constant(filename string) object.Object {
+	switch tag := d.byte(); tag {
+	case tagInteger:
+		return &object.Integer{Value: d.varint()}
+	case tagFloat:
+		b := d.bytes(8)
+		if b == nil {
+			return nil
+		}
+		return &object.Float{Value: math.Float64frombits(binary.BigEndian.Uint64(b))}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagFunction:
+		return d.function(filename)
+	default:
+		d.fail("unknown constant tag %q", tag)
+		return nil
+	}
+}
+
+func (d *decoder) function(filename string) *object.CompiledFunction {
+	fn := &object.CompiledFunction{
+		Name:          d.string(),
+		Source:        d.string(),
+		NumLocals:     d.uvarint(),
+		NumParameters: d.uvarint(),
+	}
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		fn.Captures = append(fn.Captures, object.Capture{Local: d.byte() != 0, Index: d.uvarint()})
+	}
+	fn.Instructions = code.Instructions(d.bytes(d.uvarint()))
+	n = d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		line := code.LineInfo{Offset: d.uvarint()}
+		line.Span.Start = d.pos(filename)
+		line.Span.End = d.pos(filename)
+		fn.Lines = append(fn.Lines, line)
+	}
+	return fn
+}
+
+func (d *decoder) pos(filename string) token.Pos {
+	pos := token.Pos{Offset: d.uvarint(), Line: d.uvarint(), Column: d.uvarint()}
+	if pos.IsValid() {
+		pos.Filename = filename
+	}
+	return pos
+}
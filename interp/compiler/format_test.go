@@ -0,0 +1,240 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go_interp/interp/code"
+	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
+)
+
+func compileFile(t *testing.T, filename, input string) *File {
+	t.Helper()
+	program := parser.Parse(lexer.LoadFile(filename, input)).ParseProgram()
+	bytecode, err := Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return &File{SourceHash: HashSource(input), Filename: filename, SourcePath: filename, Bytecode: bytecode}
+}
+
+func encodeFile(t *testing.T, f *File) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, f); err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []string{
+		"",
+		"1 + 2",
+		`let s = "héllo"; s[1:] + "${-7 * 2.5}"`,
+		"let adder = fn(a) { fn(b) { a + b } }; adder(1)(2)",
+		"let f = fn() { let n = 0; for (i in range(3)) { n += i } n }; puts(f())",
+		`let h = {1: [true, false], "k": null_value}; len(h)`,
+	}
+
+	for _, input := range tests {
+		f := compileFile(t, "main.mk", input)
+		data := encodeFile(t, f)
+		if !IsBytecode(data) {
+			t.Fatalf("%q: missing magic header", input)
+		}
+
+		decoded, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%q: decode error: %s", input, err)
+		}
+		if decoded.SourceHash != f.SourceHash || decoded.Filename != f.Filename || decoded.SourcePath != f.SourcePath {
+			t.Errorf("%q: wrong header. got hash=%x filename=%q source path=%q",
+				input, decoded.SourceHash, decoded.Filename, decoded.SourcePath)
+		}
+		if len(decoded.Bytecode.Globals) != len(f.Bytecode.Globals) {
+			t.Errorf("%q: wrong globals. expected=%v, got=%v", input, f.Bytecode.Globals, decoded.Bytecode.Globals)
+		}
+		for i, name := range f.Bytecode.Globals {
+			if decoded.Bytecode.Globals[i] != name {
+				t.Errorf("%q: wrong global %d. expected=%q, got=%q", input, i, name, decoded.Bytecode.Globals[i])
+			}
+		}
+
+		testDecodedFunction(t, input, f.Bytecode.Main, decoded.Bytecode.Main)
+		if len(decoded.Bytecode.Constants) != len(f.Bytecode.Constants) {
+			t.Fatalf("%q: wrong number of constants. expected=%d, got=%d",
+				input, len(f.Bytecode.Constants), len(decoded.Bytecode.Constants))
+		}
+		for i, constant := range f.Bytecode.Constants {
+			got := decoded.Bytecode.Constants[i]
+			if fn, ok := constant.(*object.CompiledFunction); ok {
+				testDecodedFunction(t, input, fn, got.(*object.CompiledFunction))
+				continue
+			}
+			if got.Type() != constant.Type() || got.Inspect() != constant.Inspect() {
+				t.Errorf("%q: wrong constant %d. expected=%s %s, got=%s %s",
+					input, i, constant.Type(), constant.Inspect(), got.Type(), got.Inspect())
+			}
+		}
+	}
+}
+
+func testDecodedFunction(t *testing.T, input string, expected, got *object.CompiledFunction) {
+	t.Helper()
+	if got.Name != expected.Name || got.Source != expected.Source ||
+		got.NumLocals != expected.NumLocals || got.NumParameters != expected.NumParameters {
+		t.Errorf("%q: wrong function. expected=%+v, got=%+v", input, expected, got)
+	}
+	if !bytes.Equal(got.Instructions, expected.Instructions) {
+		t.Errorf("%q: wrong instructions.\nexpected=%s\ngot=%s", input, expected.Instructions, got.Instructions)
+	}
+	if len(got.Captures) != len(expected.Captures) ||
+		(len(got.Captures) != 0 && !reflect.DeepEqual(got.Captures, expected.Captures)) {
+		t.Errorf("%q: wrong captures. expected=%v, got=%v", input, expected.Captures, got.Captures)
+	}
+	if len(got.Lines) != len(expected.Lines) ||
+		(len(got.Lines) != 0 && !reflect.DeepEqual(got.Lines, expected.Lines)) {
+		t.Errorf("%q: wrong line table. expected=%v, got=%v", input, expected.Lines, got.Lines)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data := encodeFile(t, compileFile(t, "main.mk", `let f = fn(x) { x * 2 }; f(21) + len("abc")`))
+
+	newVersion := append([]byte{}, data...)
+	binary.BigEndian.PutUint16(newVersion[len(Magic):], FormatVersion+1)
+
+	// 把第一个OpConstant的操作数改为不存在的常量
+	badConstant := append([]byte{}, data...)
+	main := compileFile(t, "main.mk", `let f = fn(x) { x * 2 }; f(21) + len("abc")`).Bytecode.Main
+	at := bytes.Index(badConstant, main.Instructions)
+	for i := 0; i < len(main.Instructions); {
+		def, _ := code.Lookup(main.Instructions[i])
+		if code.Opcode(main.Instructions[i]) == code.OpConstant {
+			binary.BigEndian.PutUint16(badConstant[at+i+1:], 999)
+			break
+		}
+		_, n := code.ReadOperands(def, main.Instructions[i+1:])
+		i += 1 + n
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", nil, ErrFormat},
+		{"source", []byte("let a = 1;"), ErrFormat},
+		{"version", newVersion, ErrVersion},
+		{"trailing", append(append([]byte{}, data...), 0), ErrFormat},
+		{"constant", badConstant, ErrFormat},
+	}
+	// 任意位置截断的文件都应报错而不是panic
+	for i := len(Magic); i < len(data); i++ {
+		tests = append(tests, struct {
+			name     string
+			data     []byte
+			expected error
+		}{"truncated", data[:i], ErrFormat})
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s (%d bytes): expected %v, got %v", tt.name, len(tt.data), tt.expected, err)
+		}
+	}
+}
+
+func TestDecodeInvalidFunctions(t *testing.T) {
+	// 常量中依次为内层的匿名函数(捕获a和b)和f
+	const input = "let f = fn(a) { let b = a; if (b) { fn() { a + b } } else { a } }; f(1)()"
+
+	tests := []struct {
+		name     string
+		corrupt  func(main, f, inner *object.CompiledFunction)
+		expected string
+	}{
+		{"locals", func(main, f, inner *object.CompiledFunction) { f.NumLocals = 300 }, "invalid local count 300"},
+		{"parameters", func(main, f, inner *object.CompiledFunction) { f.NumParameters = 3 }, "3 parameters exceed 2 locals"},
+		{"main parameters", func(main, f, inner *object.CompiledFunction) {
+			main.NumLocals, main.NumParameters = 1, 1
+		}, "main program has 1 parameters"},
+		{"local index", func(main, f, inner *object.CompiledFunction) { f.NumLocals = 1 }, "local 1 out of range"},
+		{"free index", func(main, f, inner *object.CompiledFunction) {
+			inner.Captures = inner.Captures[:1]
+		}, "free variable 1 out of range"},
+		{"capture count", func(main, f, inner *object.CompiledFunction) {
+			inner.Captures = append(inner.Captures, object.Capture{Local: true, Index: 0})
+		}, "closure operand 2 does not match 3 captures"},
+		{"local capture", func(main, f, inner *object.CompiledFunction) {
+			inner.Captures[1].Index = 5
+		}, "capture 5 out of range"},
+		{"free capture", func(main, f, inner *object.CompiledFunction) {
+			inner.Captures[0].Local = false
+		}, "capture 0 out of range"},
+		{"jump past end", func(main, f, inner *object.CompiledFunction) {
+			setJumpTarget(f, len(f.Instructions)+5)
+		}, "invalid jump target"},
+		{"jump into operand", func(main, f, inner *object.CompiledFunction) {
+			setJumpTarget(f, 1)
+		}, "invalid jump target 1"},
+		{"missing return", func(main, f, inner *object.CompiledFunction) {
+			f.Instructions = f.Instructions[:len(f.Instructions)-1]
+		}, "does not end with a return"},
+		{"truncated operand", func(main, f, inner *object.CompiledFunction) {
+			// 去掉OpReturnValue和之前的OpGetLocal的操作数
+			f.Instructions = f.Instructions[:len(f.Instructions)-2]
+		}, "truncated OpGetLocal"},
+		{"empty", func(main, f, inner *object.CompiledFunction) { f.Instructions = nil }, "empty function"},
+	}
+
+	for _, tt := range tests {
+		file := compileFile(t, "main.mk", input)
+		var functions []*object.CompiledFunction
+		for _, constant := range file.Bytecode.Constants {
+			if fn, ok := constant.(*object.CompiledFunction); ok {
+				functions = append(functions, fn)
+			}
+		}
+		if len(functions) != 2 || functions[1].Name != "f" {
+			t.Fatalf("unexpected functions %v", functions)
+		}
+		tt.corrupt(file.Bytecode.Main, functions[1], functions[0])
+
+		_, err := Decode(bytes.NewReader(encodeFile(t, file)))
+		if !errors.Is(err, ErrFormat) || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+// setJumpTarget 修改fn中第一条跳转指令的目标
+func setJumpTarget(fn *object.CompiledFunction, target int) {
+	for i := 0; i < len(fn.Instructions); {
+		def, _ := code.Lookup(fn.Instructions[i])
+		switch code.Opcode(fn.Instructions[i]) {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+			copy(fn.Instructions[i:], code.Make(code.Opcode(fn.Instructions[i]), target))
+			return
+		}
+		_, n := code.ReadOperands(def, fn.Instructions[i+1:])
+		i += 1 + n
+	}
+}
+
+func TestHashSource(t *testing.T) {
+	if HashSource("1 + 2") != HashSource("1 + 2") {
+		t.Errorf("hash of the same source differs")
+	}
+	if HashSource("1 + 2") == HashSource("1 + 3") {
+		t.Errorf("hash of different sources is equal")
+	}
+}
@@ -0,0 +1,157 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+// Symbol 标识符编译后的存储位置; Index为相应作用域中的下标
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable 一个函数(或顶层程序)的符号表.
+// 与求值器的环境一致, 只有函数和循环体引入新的作用域: 顶层的let绑定全局变量,
+// 函数中以及循环体中的let绑定当前函数的局部变量, 循环体结束后其局部变量的槽位可以复用
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// blocks 作用域栈, blocks[0]是函数本身的作用域(顶层程序则为全局作用域), 其余为嵌套的循环体
+	blocks []map[string]Symbol
+	// blockStart 各个循环体的第一个局部变量的下标
+	blockStart []int
+
+	numGlobals int
+	globals    []string
+	numLocals  int
+	maxLocals  int
+
+	// FreeSymbols 捕获的外层变量, 按捕获的顺序排列; 保存的是外层作用域中的原始符号
+	FreeSymbols []Symbol
+	free        map[string]Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		blocks: []map[string]Symbol{{}},
+		free:   map[string]Symbol{},
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define 在当前作用域中定义name; 同一作用域中重复定义时复用原来的位置,
+// 这样之前创建的闭包也能看到新的值
+func (s *SymbolTable) Define(name string) Symbol {
+	block := s.blocks[len(s.blocks)-1]
+	if symbol, ok := block[name]; ok {
+		return symbol
+	}
+
+	var symbol Symbol
+	if s.Outer == nil && len(s.blocks) == 1 {
+		symbol = s.defineGlobal(name)
+	} else {
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: s.numLocals}
+		s.numLocals++
+		if s.numLocals > s.maxLocals {
+			s.maxLocals = s.numLocals
+		}
+	}
+	block[name] = symbol
+	return symbol
+}
+
+// DefineGlobal 在最外层的全局作用域中定义name. 用于编译时尚未定义的标识符,
+// 它们可能在之后的顶层语句中才绑定, 运行时读取未绑定的全局变量会报错
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	if symbol, ok := s.blocks[0][name]; ok {
+		return symbol
+	}
+	symbol := s.defineGlobal(name)
+	s.blocks[0][name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineGlobal(name string) Symbol {
+	symbol := Symbol{Name: name, Scope: GlobalScope, Index: s.numGlobals}
+	s.numGlobals++
+	s.globals = append(s.globals, name)
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.free[original.Name] = symbol
+	return symbol
+}
+
+// Resolve 由内向外查找name. 外层函数的局部变量(包括外层函数已捕获的变量)
+// 在当前函数中成为自由变量; 全局变量直接引用
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if symbol, ok := s.blocks[i][name]; ok {
+			return symbol, true
+		}
+	}
+	if symbol, ok := s.free[name]; ok {
+		return symbol, true
+	}
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.Resolve(name)
+	if !ok {
+		return symbol, false
+	}
+	if symbol.Scope == GlobalScope {
+		return symbol, true
+	}
+	return s.defineFree(symbol), true
+}
+
+// PushBlock 进入循环体的作用域
+func (s *SymbolTable) PushBlock() {
+	s.blocks = append(s.blocks, map[string]Symbol{})
+	s.blockStart = append(s.blockStart, s.numLocals)
+}
+
+// PopBlock 离开循环体的作用域, 其中的局部变量不再可见
+func (s *SymbolTable) PopBlock() {
+	s.numLocals = s.blockStart[len(s.blockStart)-1]
+	s.blocks = s.blocks[:len(s.blocks)-1]
+	s.blockStart = s.blockStart[:len(s.blockStart)-1]
+}
+
+// BlockLocals 返回当前循环体的第一个局部变量的下标以及其中定义的变量个数
+func (s *SymbolTable) BlockLocals() (first, count int) {
+	if len(s.blockStart) == 0 {
+		return 0, 0
+	}
+	return s.blockStart[len(s.blockStart)-1], len(s.blocks[len(s.blocks)-1])
+}
+
+// NumLocals 函数需要的局部变量槽位数, 包括各个循环体中的局部变量
+func (s *SymbolTable) NumLocals() int {
+	return s.maxLocals
+}
+
+// Globals 返回全局变量的名字, 下标与符号的Index一致; 只对最外层的符号表有意义
+func (s *SymbolTable) Globals() []string {
+	return s.globals
+}
@@ -0,0 +1,170 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	b := global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	c := local.Define("c")
+	d := local.Define("d")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+	for _, got := range []Symbol{a, b, c, d} {
+		if got != expected[got.Name] {
+			t.Errorf("expected %s to be %+v, got=%+v", got.Name, expected[got.Name], got)
+		}
+	}
+
+	for name, want := range expected {
+		got, ok := local.Resolve(name)
+		if !ok {
+			t.Errorf("name %s not resolvable", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, want, got)
+		}
+	}
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a gave %+v, want %+v", again, a)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	third := NewEnclosedSymbolTable(second)
+
+	tests := []struct {
+		table        *SymbolTable
+		expected     []Symbol
+		freeSymbols  []Symbol
+		unresolvable []string
+	}{
+		{
+			second,
+			[]Symbol{
+				{Name: "a", Scope: GlobalScope, Index: 0},
+				{Name: "b", Scope: FreeScope, Index: 0},
+				{Name: "c", Scope: LocalScope, Index: 0},
+			},
+			[]Symbol{
+				{Name: "b", Scope: LocalScope, Index: 0},
+			},
+			[]string{"d"},
+		},
+		{
+			third,
+			[]Symbol{
+				{Name: "a", Scope: GlobalScope, Index: 0},
+				{Name: "c", Scope: FreeScope, Index: 0},
+				{Name: "b", Scope: FreeScope, Index: 1},
+			},
+			// b在second中已经是自由变量, third捕获的是second的自由变量
+			[]Symbol{
+				{Name: "c", Scope: LocalScope, Index: 0},
+				{Name: "b", Scope: FreeScope, Index: 0},
+			},
+			[]string{"d"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, want := range tt.expected {
+			got, ok := tt.table.Resolve(want.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", want.Name)
+				continue
+			}
+			if got != want {
+				t.Errorf("expected %s to resolve to %+v, got=%+v", want.Name, want, got)
+			}
+		}
+
+		if len(tt.table.FreeSymbols) != len(tt.freeSymbols) {
+			t.Errorf("wrong number of free symbols. got=%d, want=%d", len(tt.table.FreeSymbols), len(tt.freeSymbols))
+			continue
+		}
+		for i, want := range tt.freeSymbols {
+			if got := tt.table.FreeSymbols[i]; got != want {
+				t.Errorf("wrong free symbol. got=%+v, want=%+v", got, want)
+			}
+		}
+
+		for _, name := range tt.unresolvable {
+			if _, ok := tt.table.Resolve(name); ok {
+				t.Errorf("name %s resolved, but was expected not to", name)
+			}
+		}
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("x")
+
+	// 顶层循环体中的变量是局部变量
+	global.PushBlock()
+	inner := global.Define("x")
+	if want := (Symbol{Name: "x", Scope: LocalScope, Index: 0}); inner != want {
+		t.Errorf("x in block = %+v, want %+v", inner, want)
+	}
+	global.Define("y")
+	if first, count := global.BlockLocals(); first != 0 || count != 2 {
+		t.Errorf("BlockLocals() = %d, %d, want 0, 2", first, count)
+	}
+	global.PopBlock()
+
+	if got, _ := global.Resolve("x"); got.Scope != GlobalScope {
+		t.Errorf("x after block = %+v, want the global", got)
+	}
+	if _, ok := global.Resolve("y"); ok {
+		t.Errorf("y resolvable after its block ended")
+	}
+
+	// 之后的循环体复用已结束的循环体的槽位
+	global.PushBlock()
+	if z := global.Define("z"); z.Index != 0 {
+		t.Errorf("z.Index = %d, want 0", z.Index)
+	}
+	global.PopBlock()
+
+	if n := global.NumLocals(); n != 2 {
+		t.Errorf("NumLocals() = %d, want 2", n)
+	}
+}
+
+func TestDefineGlobal(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(NewEnclosedSymbolTable(global))
+	later := local.DefineGlobal("later")
+	if want := (Symbol{Name: "later", Scope: GlobalScope, Index: 1}); later != want {
+		t.Errorf("DefineGlobal(later) = %+v, want %+v", later, want)
+	}
+	if got := global.Define("later"); got != later {
+		t.Errorf("Define(later) = %+v, want %+v", got, later)
+	}
+
+	globals := global.Globals()
+	if len(globals) != 2 || globals[0] != "a" || globals[1] != "later" {
+		t.Errorf("Globals() = %v", globals)
+	}
+}
@@ -0,0 +1,143 @@
+package diagnostic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go_interp/model/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Code 诊断信息的稳定编号, 供工具按类别过滤, 不随消息文本变化
+type Code string
+
+const (
+	// 词法分析
+	IllegalCharacter    Code = "L001"
+	UnterminatedString  Code = "L002"
+	InvalidEscape       Code = "L003"
+	InvalidUTF8         Code = "L004"
+	UnterminatedComment Code = "L005"
+	MalformedNumber     Code = "L006"
+
+	// 语法分析
+	UnexpectedToken Code = "P001"
+	NoPrefixParseFn Code = "P002"
+	InvalidInteger  Code = "P003"
+	InvalidFloat    Code = "P004"
+	InvalidAssign   Code = "P005"
+	BranchOutside   Code = "P006"
+
+	// 运行时
+	TypeMismatch       Code = "R001"
+	UnknownOperator    Code = "R002"
+	UnknownIdentifier  Code = "R003"
+	DivisionByZero     Code = "R004"
+	NegativeShift      Code = "R005"
+	NotCallable        Code = "R006"
+	WrongArgumentCount Code = "R007"
+	ArgumentType       Code = "R008"
+	InvalidArgument    Code = "R009"
+	IndexOutOfRange    Code = "R010"
+	UnhashableKey      Code = "R011"
+	NotIterable        Code = "R012"
+	StackOverflow      Code = "R013"
+)
+
+// Diagnostic 一条结构化的诊断信息
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Message  string
+	Span     token.Span
+	// Expected/Actual 仅在"期望某个词法单元"这类错误中设置
+	Expected token.TokenType
+	Actual   token.TokenType
+	// Hint 可选的修复建议
+	Hint string
+	// Notes 附加说明, 如运行时错误的调用栈, 每条输出为一行
+	Notes []string
+}
+
+// Error formats the diagnostic as "file:line:col: message".
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Message)
+}
+
+func (d *Diagnostic) String() string {
+	return d.Error()
+}
+
+// List 多条诊断信息, 实现error接口以便整体返回
+type List []*Diagnostic
+
+func (l List) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	var out strings.Builder
+	out.WriteString(l[0].Error())
+	fmt.Fprintf(&out, " (and %d more errors)", len(l)-1)
+	return out.String()
+}
+
+// Err returns nil for an empty list and the list itself otherwise.
+func (l List) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// HasErrors reports whether the list contains a diagnostic of severity Error.
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders the diagnostics by source position, keeping the report order for equal positions.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span.Start, l[j].Span.Start
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+// Messages returns the Error() text of every diagnostic.
+func (l List) Messages() []string {
+	msgs := make([]string, 0, len(l))
+	for _, d := range l {
+		msgs = append(msgs, d.Error())
+	}
+	return msgs
+}
@@ -0,0 +1,111 @@
+package diagnostic
+
+import (
+	"bytes"
+	"testing"
+
+	"go_interp/model/token"
+)
+
+func TestListError(t *testing.T) {
+	first := &Diagnostic{Message: "first", Span: token.Span{Start: token.Pos{Line: 1, Column: 2}}}
+	second := &Diagnostic{Message: "second", Span: token.Span{Start: token.Pos{Line: 3, Column: 4}}}
+
+	tests := []struct {
+		list     List
+		expected string
+	}{
+		{List{first}, "1:2: first"},
+		{List{first, second}, "1:2: first (and 1 more errors)"},
+	}
+
+	for _, tt := range tests {
+		if tt.list.Error() != tt.expected {
+			t.Errorf("Error() wrong. expected=%q, got=%q", tt.expected, tt.list.Error())
+		}
+	}
+
+	if (List{}).Err() != nil {
+		t.Errorf("empty list should have nil Err()")
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := "let x = 5;\n\tlet foo 10;\n"
+	d := &Diagnostic{
+		Severity: Error,
+		Code:     UnexpectedToken,
+		Message:  "expected next token to be =, but got INT",
+		Span: token.Span{
+			Start: token.Pos{Filename: "main.mk", Offset: 20, Line: 2, Column: 10},
+			End:   token.Pos{Filename: "main.mk", Offset: 22, Line: 2, Column: 12},
+		},
+		Hint: "let statements have the form let <name> = <expression>;",
+	}
+
+	var out bytes.Buffer
+	Render(&out, source, d)
+
+	expected := "main.mk:2:10: error[P001]: expected next token to be =, but got INT\n" +
+		" 2 | \tlet foo 10;\n" +
+		"   | \t        ^~\n" +
+		"   = hint: let statements have the form let <name> = <expression>;\n"
+	if out.String() != expected {
+		t.Errorf("Render() wrong.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestRenderMultiByte(t *testing.T) {
+	source := "let 变量 = 真;"
+	d := &Diagnostic{
+		Severity: Error,
+		Code:     IllegalCharacter,
+		Message:  "identifier not found: 真",
+		Span: token.Span{
+			Start: token.Pos{Offset: 13, Line: 1, Column: 10},
+			End:   token.Pos{Offset: 16, Line: 1, Column: 11},
+		},
+	}
+
+	var out bytes.Buffer
+	Render(&out, source, d)
+
+	expected := "1:10: error[L001]: identifier not found: 真\n" +
+		" 1 | let 变量 = 真;\n" +
+		"   |          ^\n"
+	if out.String() != expected {
+		t.Errorf("Render() wrong.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestRenderNotes(t *testing.T) {
+	source := "let f = fn() { 1 + true };\nf()"
+	d := &Diagnostic{
+		Severity: Error,
+		Code:     TypeMismatch,
+		Message:  "type mismatch: INTEGER + BOOLEAN",
+		Span: token.Span{
+			Start: token.Pos{Offset: 15, Line: 1, Column: 16},
+			End:   token.Pos{Offset: 23, Line: 1, Column: 24},
+		},
+		Notes: []string{"in f, called at 2:1"},
+	}
+
+	var out bytes.Buffer
+	Render(&out, source, d)
+
+	expected := "1:16: error[R001]: type mismatch: INTEGER + BOOLEAN\n" +
+		" 1 | let f = fn() { 1 + true };\n" +
+		"   |                ^~~~~~~~\n" +
+		"   = note: in f, called at 2:1\n"
+	if out.String() != expected {
+		t.Errorf("Render() wrong.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+
+	// 没有源码时说明仍然输出
+	out.Reset()
+	Render(&out, "", &Diagnostic{Message: "boom", Notes: []string{"in f"}})
+	if expected := "-: error: boom\n  = note: in f\n"; out.String() != expected {
+		t.Errorf("Render() wrong.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
@@ -0,0 +1,96 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Render 以编译器风格输出诊断信息: 首行为位置与消息, 随后是出错的源码行以及下划线
+//
+//	main.mk:2:5: error[P001]: expected next token to be IDENT, but got =
+//	  2 | let = 10;
+//	    |     ^
+//	    = hint: ...
+func Render(w io.Writer, source string, d *Diagnostic) {
+	start := d.Span.Start
+	fmt.Fprintf(w, "%s: %s", start, d.Severity)
+	if d.Code != "" {
+		fmt.Fprintf(w, "[%s]", d.Code)
+	}
+	fmt.Fprintf(w, ": %s\n", d.Message)
+
+	line, ok := sourceLine(source, start.Offset)
+	if !start.IsValid() || !ok {
+		renderNotes(w, " ", d)
+		return
+	}
+
+	gutter := fmt.Sprintf("%d", start.Line)
+	blank := strings.Repeat(" ", len(gutter))
+	fmt.Fprintf(w, " %s | %s\n", gutter, line)
+
+	// 下划线之前的部分沿用源码行中的制表符, 保证在终端中对齐
+	col := start.Offset - lineStart(source, start.Offset)
+	if col > len(line) {
+		col = len(line)
+	}
+	var pad strings.Builder
+	for _, r := range line[:col] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	// 跨行的区间只标记到行尾
+	width := 1
+	if end := d.Span.End.Offset; end > start.Offset {
+		stop := col + end - start.Offset
+		if stop > len(line) {
+			stop = len(line)
+		}
+		if n := utf8.RuneCountInString(line[col:stop]); n > 1 {
+			width = n
+		}
+	}
+	fmt.Fprintf(w, " %s | %s^%s\n", blank, pad.String(), strings.Repeat("~", width-1))
+
+	renderNotes(w, " "+blank, d)
+}
+
+// renderNotes 输出修复建议和附加说明, indent与源码行的行号栏对齐
+func renderNotes(w io.Writer, indent string, d *Diagnostic) {
+	if d.Hint != "" {
+		fmt.Fprintf(w, "%s = hint: %s\n", indent, d.Hint)
+	}
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", indent, note)
+	}
+}
+
+// RenderAll renders every diagnostic of the list in order.
+func RenderAll(w io.Writer, source string, l List) {
+	for _, d := range l {
+		Render(w, source, d)
+	}
+}
+
+func lineStart(source string, offset int) int {
+	return strings.LastIndexByte(source[:offset], '\n') + 1
+}
+
+// sourceLine 返回offset所在的整行(不含换行符)
+func sourceLine(source string, offset int) (string, bool) {
+	if offset < 0 || offset > len(source) {
+		return "", false
+	}
+	start := lineStart(source, offset)
+	end := strings.IndexByte(source[start:], '\n')
+	if end < 0 {
+		end = len(source) - start
+	}
+	return strings.TrimSuffix(source[start:start+end], "\r"), true
+}
@@ -0,0 +1,845 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"go_interp/interp/ast"
+	"go_interp/interp/builtins"
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/object"
+	"go_interp/model/token"
+)
+
+// 与object中的实例相同, 内置函数返回的null和布尔值也可以按指针比较
+var (
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+const (
+	// MaxCallDepth 函数调用最多嵌套的层数, 超过时为栈溢出错误; 虚拟机使用相同的限制
+	MaxCallDepth = 10000
+	// OverflowStackFrames 栈溢出时调用栈有上万层, 错误中只记录最内层的几层
+	OverflowStackFrames = 8
+)
+
+// Eval 对AST节点求值
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := evalNode(node, env)
+
+	// 错误由产生它的最内层节点记录位置, 外层节点原样传递
+	if err, ok := result.(*object.Error); ok && !err.Span.Start.IsValid() {
+		err.Span = token.Span{Start: node.Pos(), End: node.End()}
+	}
+	return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	// 语句
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+	case *ast.LetStatement:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		env.Set(node.Name.Value, val)
+		return nil
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.ReturnStatement:
+		val := Eval(node.Value, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	// 表达式
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isAbrupt(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
+			return args[0]
+		}
+		return applyFunction(node, function, args, env)
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+		left := Eval(node.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isAbrupt(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	}
+	// 缺失的值(如解析失败留下的nil表达式)统一视为null
+	return NULL
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, stmt := range program.Statements {
+		result = Eval(stmt, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// evalBlockStatement 与evalProgram不同, 遇到ReturnValue时不解包, 原样向外传递直到最外层;
+// break和continue同样向外传递, 直到所在的循环
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, stmt := range block.Statements {
+		result = Eval(stmt, env)
+
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// evalWhileStatement 循环语句本身没有值
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		result, done := evalLoopBody(node.Body, object.NewEnclosedEnvironment(env))
+		if done {
+			return result
+		}
+	}
+}
+
+// evalForStatement 遍历数组的元素、哈希表的键(按插入顺序)或字符串的字符.
+// 每次循环都在新的环境中绑定循环变量, 闭包捕获的是当次循环的值, 循环变量在循环之后不可见
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	items, err := iterationItems(iterable)
+	if err != nil {
+		err.Span = token.Span{Start: node.Iterable.Pos(), End: node.Iterable.End()}
+		return err
+	}
+
+	for _, item := range items {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Variable.Value, item)
+
+		result, done := evalLoopBody(node.Body, loopEnv)
+		if done {
+			return result
+		}
+	}
+	return nil
+}
+
+// iterationItems 返回for循环依次绑定到循环变量的值
+func iterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		// 遍历开始时的快照, 循环体中修改元素不影响遍历的次数
+		items = append(items, iterable.Elements...)
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			items = append(items, pair.Key)
+		}
+	case *object.String:
+		items = stringElements(iterable.Value)
+	default:
+		return nil, newError(diagnostic.NotIterable, "cannot iterate over %s", iterable.Type())
+	}
+	return items, nil
+}
+
+// evalLoopBody 执行一次循环体, done为true时循环结束, 此时result为循环语句的结果
+// (break时为nil, return或出错时为相应的值)
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	switch result := Eval(body, env).(type) {
+	case *object.Break:
+		return nil, true
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	}
+	return nil, false
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isAbrupt(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	}
+	return NULL
+}
+
+// evalExpressions 从左到右求值, 遇到错误(或return、break、continue)时只返回它
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isAbrupt(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+// applyFunction 函数体中产生的错误在向外传递时记录调用栈; env为调用处的环境
+func applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return builtin.Fn(args...)
+	}
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError(diagnostic.NotCallable, "not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return newError(diagnostic.WrongArgumentCount, "wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+	}
+
+	if env.CallDepth() >= MaxCallDepth {
+		return newError(diagnostic.StackOverflow, "stack overflow")
+	}
+
+	extendedEnv := extendFunctionEnv(function, args, env)
+	evaluated := Eval(function.Body, extendedEnv)
+	if err, ok := evaluated.(*object.Error); ok {
+		if err.Code == diagnostic.StackOverflow && len(err.Stack) >= OverflowStackFrames {
+			return err
+		}
+		name := function.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		err.Stack = append(err.Stack, object.Frame{Function: name, Pos: call.Pos()})
+		return err
+	}
+	return unwrapReturnValue(evaluated)
+}
+
+// extendFunctionEnv 参数绑定在以函数定义处环境为外层的新环境中, 而不是调用处的环境
+func extendFunctionEnv(fn *object.Function, args []object.Object, caller *object.Environment) *object.Environment {
+	env := object.NewCallEnvironment(fn.Env, caller)
+
+	for i, param := range fn.Parameters {
+		env.Set(param.Value, args[i])
+	}
+	return env
+}
+
+// unwrapReturnValue return只结束当前函数, 不能继续向调用者之外传递
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	if obj == nil {
+		return NULL
+	}
+	return obj
+}
+
+// evalAssignExpression 赋值表达式的值为赋给目标的值. 复合赋值先读取目标的当前值,
+// 再按相应的二元运算符计算
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		current, ok := env.Get(target.Value)
+		if !ok {
+			err := newError(diagnostic.UnknownIdentifier, "cannot assign to undeclared identifier: %s", target.Value)
+			err.Span = token.Span{Start: target.Pos(), End: target.End()}
+			return err
+		}
+		val := evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+		env.Assign(target.Value, val)
+		return val
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isAbrupt(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isAbrupt(index) {
+			return index
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIndexExpression(left, index)
+			if isAbrupt(current) {
+				return current
+			}
+		}
+		val := evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+		return evalIndexAssignment(left, index, val)
+	}
+	return newError(diagnostic.UnknownOperator, "cannot assign to %s", node.Target.String())
+}
+
+// evalAssignedValue 求值赋值号右侧; 对于复合赋值, 与current做相应的二元运算
+func evalAssignedValue(node *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isAbrupt(val) || node.Operator == "=" {
+		return val
+	}
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return evalInfixExpression(operator, current, val)
+}
+
+// evalIndexAssignment 原地修改数组元素或哈希表的键值, 数组下标必须已存在
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError(diagnostic.TypeMismatch, "index must be INTEGER, got %s", index.Type())
+		}
+		i := idx.Value
+		if i < 0 {
+			i += int64(len(left.Elements))
+		}
+		if i < 0 || i >= int64(len(left.Elements)) {
+			return newError(diagnostic.IndexOutOfRange, "index %d out of range for ARRAY of length %d",
+				idx.Value, len(left.Elements))
+		}
+		left.Elements[i] = val
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", index.Type())
+		}
+		left.Set(key, val)
+		return val
+	}
+	return newError(diagnostic.UnknownOperator, "index assignment not supported: %s", left.Type())
+}
+
+// evalHashLiteral 按源码顺序求值, 重复的键保留第一次出现的位置和最后一次的值
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isAbrupt(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return unhashableKeyError(pair.Key, key)
+		}
+
+		value := Eval(pair.Value, env)
+		if isAbrupt(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+	return hash
+}
+
+// unhashableKeyError 错误位置为键本身, 而不是整个字面量
+func unhashableKeyError(node ast.Node, key object.Object) *object.Error {
+	err := newError(diagnostic.UnhashableKey, "unusable as hash key: %s", key.Type())
+	err.Span = token.Span{Start: node.Pos(), End: node.End()}
+	return err
+}
+
+// evalIndexExpression 数组按元素、字符串按字符取下标, 负数下标从末尾开始计数;
+// 哈希表中不存在的键返回null
+func evalIndexExpression(left, index object.Object) object.Object {
+	if hash, ok := left.(*object.Hash); ok {
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", index.Type())
+		}
+		if value, ok := hash.Get(key); ok {
+			return value
+		}
+		return NULL
+	}
+
+	var elements []object.Object
+	switch left := left.(type) {
+	case *object.Array:
+		elements = left.Elements
+	case *object.String:
+		elements = stringElements(left.Value)
+	default:
+		return newError(diagnostic.UnknownOperator, "index operator not supported: %s", left.Type())
+	}
+
+	idx, ok := index.(*object.Integer)
+	if !ok {
+		return newError(diagnostic.TypeMismatch, "index must be INTEGER, got %s", index.Type())
+	}
+	i := idx.Value
+	if i < 0 {
+		i += int64(len(elements))
+	}
+	if i < 0 || i >= int64(len(elements)) {
+		return newError(diagnostic.IndexOutOfRange, "index %d out of range for %s of length %d",
+			idx.Value, left.Type(), len(elements))
+	}
+	return elements[i]
+}
+
+// evalSliceExpression 先求值被切片的值和两端, 再按evalSlice切片
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	var low, high object.Object
+	if node.Low != nil {
+		if low = Eval(node.Low, env); isAbrupt(low) {
+			return low
+		}
+	}
+	if node.High != nil {
+		if high = Eval(node.High, env); isAbrupt(high) {
+			return high
+		}
+	}
+	return evalSlice(left, low, high)
+}
+
+// evalSlice 返回[low, high)区间的新数组或字符串; low和high为nil表示省略,
+// low默认为0, high默认为长度
+func evalSlice(left, low, high object.Object) object.Object {
+	var elements []object.Object
+	switch left := left.(type) {
+	case *object.Array:
+		elements = left.Elements
+	case *object.String:
+		elements = stringElements(left.Value)
+	default:
+		return newError(diagnostic.UnknownOperator, "slice operator not supported: %s", left.Type())
+	}
+
+	length := int64(len(elements))
+	lowIndex, errObj := sliceBound(low, 0, length, left.Type())
+	if errObj != nil {
+		return errObj
+	}
+	highIndex, errObj := sliceBound(high, length, length, left.Type())
+	if errObj != nil {
+		return errObj
+	}
+	if lowIndex > highIndex {
+		return newError(diagnostic.IndexOutOfRange, "invalid slice: low index %d > high index %d", lowIndex, highIndex)
+	}
+
+	if str, ok := left.(*object.String); ok {
+		runes := []rune(str.Value)
+		return &object.String{Value: string(runes[lowIndex:highIndex])}
+	}
+	result := make([]object.Object, highIndex-lowIndex)
+	copy(result, elements[lowIndex:highIndex])
+	return &object.Array{Elements: result}
+}
+
+// sliceBound 检查切片的一端, 省略时为def; 与下标不同, 长度本身也是合法的边界
+func sliceBound(val object.Object, def, length int64, t object.ObjectType) (int64, *object.Error) {
+	if val == nil {
+		return def, nil
+	}
+	bound, ok := val.(*object.Integer)
+	if !ok {
+		return 0, newError(diagnostic.TypeMismatch, "slice index must be INTEGER, got %s", val.Type())
+	}
+
+	i := bound.Value
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i > length {
+		return 0, newError(diagnostic.IndexOutOfRange, "slice index %d out of range for %s of length %d",
+			bound.Value, t, length)
+	}
+	return i, nil
+}
+
+// stringElements 将字符串拆分为单个字符的字符串
+func stringElements(s string) []object.Object {
+	elements := []object.Object{}
+	for _, r := range s {
+		elements = append(elements, &object.String{Value: string(r)})
+	}
+	return elements
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	val, ok := env.Get(node.Value)
+	if ok {
+		return val
+	}
+	// 环境中的绑定可以遮蔽同名的内置函数
+	if builtin, ok := lookupBuiltin(env, node.Value); ok {
+		return builtin
+	}
+	return newError(diagnostic.UnknownIdentifier, "identifier not found: %s", node.Value)
+}
+
+// lookupBuiltin 在环境的内置函数表中查找name, 环境没有指定时使用builtins.Default
+func lookupBuiltin(env *object.Environment, name string) (*object.Builtin, bool) {
+	if b := env.Builtins(); b != nil {
+		return b.Lookup(name)
+	}
+	return builtins.Lookup(name)
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		if right.Type() != object.INTEGER_OBJ {
+			return newError(diagnostic.UnknownOperator, "unknown operator: ~%s", right.Type())
+		}
+		return &object.Integer{Value: ^right.(*object.Integer).Value}
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: -%s", right.Type())
+	}
+}
+
+// evalLogicalExpression &&和||短路求值: 左侧已能决定结果时不再对右侧求值, 结果总是布尔值
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := Eval(node.Right, env)
+	if isAbrupt(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// 整数与浮点数混合运算时, 整数先转换为浮点数
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type():
+		return newError(diagnostic.TypeMismatch, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return &object.Integer{Value: leftVal + rightVal}
+	case "-":
+		return &object.Integer{Value: leftVal - rightVal}
+	case "*":
+		return &object.Integer{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %d / %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(diagnostic.DivisionByZero, "division by zero: %d %% %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newError(diagnostic.NegativeShift, "negative shift count: %d << %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal << uint64(rightVal)}
+	case ">>":
+		if rightVal < 0 {
+			return newError(diagnostic.NegativeShift, "negative shift count: %d >> %d", leftVal, rightVal)
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(diagnostic.UnknownOperator, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalInterpolatedString 依次求值各部分并拼接, 非字符串的值使用其Inspect()结果
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out bytes.Buffer
+	for _, part := range node.Parts {
+		val := Eval(part, env)
+		if isAbrupt(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+	}
+	return &object.String{Value: out.String()}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+// isTruthy null和false为假, 其余值均为真
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+// newError 错误的位置由Eval在返回时补上
+func newError(code diagnostic.Code, format string, a ...interface{}) *object.Error {
+	return &object.Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// isAbrupt 判断子表达式的结果是否结束了所在表达式的求值: 错误, 以及分支中的return、
+// break和continue, 它们原样向外传递, 不能作为运算的操作数
+func isAbrupt(obj object.Object) bool {
+	if obj != nil {
+		switch obj.Type() {
+		case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1045 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go_interp/interp/builtins"
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"true == true", true},
+		{"false == false", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"10 % 0", "division by zero: 10 % 0"},
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"true && undefined", "identifier not found: undefined"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"${foobar}"`, "identifier not found: foobar"},
+		{"5(1)", "not a function: INTEGER"},
+		{"fn(x) { x }(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"fn(x) { x }(y)", "identifier not found: y"},
+		{"let f = fn() { 1 + true }; f()", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testEval(input string) object.Object {
+	l := lexer.Load(input)
+	p := parser.Parse(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		// 表达式中的return结束整个函数, 不作为运算的操作数
+		{"let f = fn() { 1 + if (true) { return 5 } }; f()", 5},
+		{"let f = fn(x) { [x, if (x > 1) { return x * 2 } else { 0 }] }; f(3)", 6},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`let name = "monkey"; "hi, ${name}!"`, "hi, monkey!"},
+		{`let a = 2; "${a} * 3 = ${a * 3}, ${a > 1}"`, "2 * 3 = 6, true"},
+		{`"${"nested ${1 + 1}"}"`, "nested 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. got=%q, want=%q", str.Value, tt.expected)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"1e3 - 1", 999.0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("object is not Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("object has wrong value. got=%g, want=%g", result.Value, tt.expected)
+		}
+	}
+
+	inspects := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"1e21", "1e+21"},
+	}
+	for _, tt := range inspects {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("Inspect() wrong. got=%q, want=%q", got, tt.expected)
+		}
+	}
+
+	testBooleanObject(t, testEval("1.5 < 2"), true)
+	testBooleanObject(t, testEval("2.0 == 2"), true)
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"-16 >> 2", -4},
+		{"1 + 2 << 1", 6},
+		{"3 <= 3", true},
+		{"4 <= 3", false},
+		{"3 >= 3", true},
+		{"2 >= 3", false},
+		{"2.5 <= 2.5", true},
+		{`"abc" < "abd"`, true},
+		{`"b" >= "a"`, true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 && 0", true},
+		// 短路求值: 右侧的未定义标识符不会被求值
+		{"false && undefined", false},
+		{"true || undefined", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+	if fn.Body.String() != "(x+2)" {
+		t.Fatalf("body is not %q. got=%q", "(x+2)", fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		// return只结束所在的函数
+		{"let f = fn() { return 1; }; f(); 2", 2},
+		{"let f = fn(x) { if (x > 0) { return x; } 0 }; f(3) + f(-1)", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testNullObject(t, testEval("fn() {}()"))
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let adder = fn(x) { fn(y) { x + y } }; let addTwo = adder(2); addTwo(3);", 5},
+		{"let adder = fn(x) { fn(y) { x + y } }; adder(1)(10) + adder(2)(20);", 33},
+		// 嵌套闭包可以访问每一层外层函数的参数
+		{"let f = fn(a) { fn(b) { fn(c) { a * 100 + b * 10 + c } } }; f(1)(2)(3);", 123},
+		{"let counter = fn(n) { let m = n * 2; fn() { m + n } }; counter(3)();", 9},
+		// 函数可以作为参数传递
+		{"let apply = fn(f, x) { f(x) }; apply(fn(x) { x * x }, 4);", 16},
+		// 递归: 函数体在调用时才查找自身的名字
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5);", 120},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLexicalScoping(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		// 参数遮蔽同名的外层变量, 但不修改它
+		{"let x = 1; let f = fn(x) { x }; f(2);", 2},
+		{"let x = 1; let f = fn(x) { x }; f(2); x;", 1},
+		// 函数体内的let只在本次调用中有效
+		{"let x = 1; let f = fn() { let x = 5; x }; f() + x;", 6},
+		// 自由变量在定义处而不是调用处查找
+		{"let x = 10; let getX = fn() { x }; let g = fn(x) { getX() }; g(99);", 10},
+		{"let make = fn() { let y = 7; fn() { y } }; let y = 100; make()();", 7},
+		// 外层绑定在调用时才读取, 之后的重新绑定可见
+		{"let x = 1; let f = fn() { x }; let x = 2; f();", 2},
+		// 闭包捕获各自的环境
+		{"let mk = fn(v) { fn() { v } }; let a = mk(1); let b = mk(2); a() * 10 + b();", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	evaluated := testEval("let f = fn() { let inner = 1; inner }; f(); inner")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "identifier not found: inner" {
+		t.Errorf("local binding leaked out of function. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestErrorLocations(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedCode  diagnostic.Code
+		expectedStart string
+		expectedEnd   string
+		expectedStack []string
+	}{
+		{"5 + true", diagnostic.TypeMismatch, "1:1", "1:9", nil},
+		{"let a = 1;\n  -true", diagnostic.UnknownOperator, "2:3", "2:8", nil},
+		{"1 + foo * 2", diagnostic.UnknownIdentifier, "1:5", "1:8", nil},
+		{"let z = 0; 10 % z", diagnostic.DivisionByZero, "1:12", "1:18", nil},
+		{"1 >> -1", diagnostic.NegativeShift, "1:1", "1:8", nil},
+		{"let x = 1; x(2)", diagnostic.NotCallable, "1:12", "1:16", nil},
+		{"fn(a) { a }()", diagnostic.WrongArgumentCount, "1:1", "1:14", nil},
+		{
+			"let inner = fn(x) { x + true };\nlet outer = fn() {\n  inner(1)\n};\nouter()",
+			diagnostic.TypeMismatch, "1:21", "1:29",
+			[]string{"in inner, called at 3:3", "in outer, called at 5:1"},
+		},
+		{
+			"fn() { missing }()",
+			diagnostic.UnknownIdentifier, "1:8", "1:15",
+			[]string{"in <anonymous>, called at 1:1"},
+		},
+		// 函数值被重新绑定时保留原来的名字
+		{
+			"let f = fn() { -true }; let g = f; g()",
+			diagnostic.UnknownOperator, "1:16", "1:21",
+			[]string{"in f, called at 1:36"},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Code != tt.expectedCode {
+			t.Errorf("%q: wrong code. expected=%s, got=%s", tt.input, tt.expectedCode, errObj.Code)
+		}
+		if got := errObj.Span.Start.String(); got != tt.expectedStart {
+			t.Errorf("%q: wrong start. expected=%s, got=%s", tt.input, tt.expectedStart, got)
+		}
+		if got := errObj.Span.End.String(); got != tt.expectedEnd {
+			t.Errorf("%q: wrong end. expected=%s, got=%s", tt.input, tt.expectedEnd, got)
+		}
+
+		var stack []string
+		for _, frame := range errObj.Stack {
+			stack = append(stack, frame.String())
+		}
+		if strings.Join(stack, "\n") != strings.Join(tt.expectedStack, "\n") {
+			t.Errorf("%q: wrong stack.\nexpected=%q\ngot=     %q", tt.input, tt.expectedStack, stack)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	evaluated := testEval("let f = fn(n) { f(n + 1) };\nf(0)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Code != diagnostic.StackOverflow || errObj.Message != "stack overflow" {
+		t.Errorf("wrong error. got=%s %q", errObj.Code, errObj.Message)
+	}
+	if errObj.Span.Start.String() != "1:17" || errObj.Span.End.String() != "1:25" {
+		t.Errorf("wrong span. got=%s-%s", errObj.Span.Start, errObj.Span.End)
+	}
+	// 只记录最内层的几层调用
+	if len(errObj.Stack) != OverflowStackFrames {
+		t.Fatalf("wrong stack depth. expected=%d, got=%d", OverflowStackFrames, len(errObj.Stack))
+	}
+	for _, frame := range errObj.Stack {
+		if frame.String() != "in f, called at 1:17" {
+			t.Errorf("wrong frame %q", frame)
+		}
+	}
+
+	// 恰好MaxCallDepth层的调用可以完成, 再多一层即为栈溢出
+	countdown := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; "
+	testIntegerObject(t, testEval(fmt.Sprintf("%sf(%d)", countdown, MaxCallDepth-1)), MaxCallDepth-1)
+	evaluated = testEval(fmt.Sprintf("%sf(%d)", countdown, MaxCallDepth))
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Code != diagnostic.StackOverflow {
+		t.Errorf("expected stack overflow at depth %d. got=%T(%+v)", MaxCallDepth+1, evaluated, evaluated)
+	}
+
+	// 调用深度按调用关系计算, 与函数定义所在的位置无关
+	input := "let make = fn(n) { if (n == 0) { fn() { 1 } } else { make(n - 1) } }; let g = make(100); g()"
+	testIntegerObject(t, testEval(input), 1)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len(range(3))`, 3},
+		{`first(range(5, 8))`, 5},
+		{`last(push(range(2), 9))`, 9},
+		{`len(rest(range(4)))`, 3},
+		{`type(1.5)`, "FLOAT"},
+		{`type(len)`, "BUILTIN"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`str(1 + 2) + "!"`, "3!"},
+		{`int("12") * 2`, 24},
+		{`let f = len; f("ab")`, 2},
+		// 用户的绑定遮蔽同名的内置函数
+		{`let len = fn(x) { 42 }; len("ab")`, 42},
+		{`len(1)`, "argument 1 to len must be STRING, ARRAY or HASH, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to len: want=1, got=2"},
+		{`first(1)`, "argument 1 to first must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%s: wrong string. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: unexpected object. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+
+	testNullObject(t, testEval("first(range(0))"))
+
+	errObj, ok := testEval(`1 + len(1)`).(*object.Error)
+	if !ok || errObj.Span.Start.String() != "1:5" || errObj.Span.End.String() != "1:11" {
+		t.Errorf("builtin error has wrong location. got=%+v", errObj)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"let f = fn() { [fn(x) { x * 10 }] }; f()[0](4)", 40},
+		// 负数下标从末尾开始
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{`"héllo"[1]`, "é"},
+		{`"abc"[-1]`, "c"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: wrong result. expected=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][1:-1]", "[2, 3]"},
+		{"[1, 2, 3, 4][2:2]", "[]"},
+		{"[1, 2, 3, 4][4:]", "[]"},
+		{`"hello"[1:3]`, "el"},
+		{`"日本語"[1:]`, "本語"},
+		// 切片是新数组, 不影响原数组
+		{"let a = [1, 2, 3]; let b = a[0:2]; push(b, 9); a", "[1, 2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: wrong result. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedCode    diagnostic.Code
+		expectedMessage string
+	}{
+		{"[1, 2, 3][3]", diagnostic.IndexOutOfRange, "index 3 out of range for ARRAY of length 3"},
+		{"[1, 2, 3][-4]", diagnostic.IndexOutOfRange, "index -4 out of range for ARRAY of length 3"},
+		{"[][0]", diagnostic.IndexOutOfRange, "index 0 out of range for ARRAY of length 0"},
+		{`"ab"[2]`, diagnostic.IndexOutOfRange, "index 2 out of range for STRING of length 2"},
+		{"[1, 2][5:]", diagnostic.IndexOutOfRange, "slice index 5 out of range for ARRAY of length 2"},
+		{"[1, 2][:-3]", diagnostic.IndexOutOfRange, "slice index -3 out of range for ARRAY of length 2"},
+		{"[1, 2, 3][2:1]", diagnostic.IndexOutOfRange, "invalid slice: low index 2 > high index 1"},
+		{`[1]["a"]`, diagnostic.TypeMismatch, "index must be INTEGER, got STRING"},
+		{`[1][true:]`, diagnostic.TypeMismatch, "slice index must be INTEGER, got BOOLEAN"},
+		{"1[0]", diagnostic.UnknownOperator, "index operator not supported: INTEGER"},
+		{"1[0:1]", diagnostic.UnknownOperator, "slice operator not supported: INTEGER"},
+		{"[1, x]", diagnostic.UnknownIdentifier, "identifier not found: x"},
+		{"[1][x]", diagnostic.UnknownIdentifier, "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Code != tt.expectedCode {
+			t.Errorf("%s: wrong code. expected=%s, got=%s", tt.input, tt.expectedCode, errObj.Code)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
+	}
+	for _, pair := range result.Pairs() {
+		expectedValue, ok := expected[pair.Key.(object.Hashable).HashKey()]
+		if !ok {
+			t.Errorf("unexpected key %s in Pairs", pair.Key.Inspect())
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	if got := result.Inspect(); got != `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}` {
+		t.Errorf("wrong Inspect(). got=%s", got)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		// 类型不同的键互不相同
+		{`{1: "int", "1": "str"}[1]`, "int"},
+		{`{1: "int", "1": "str"}["1"]`, "str"},
+		// 重复的键保留最后一个值
+		{`{"a": 1, "b": 2, "a": 3}["a"]`, 3},
+		{`len({"a": 1, "b": 2, "a": 3})`, 2},
+		{`{"f": fn(x) { x * 2 }}["f"](21)`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+
+	if got := testEval(`{"b": 1, "a": 2, "b": 3}`).Inspect(); got != `{"b": 3, "a": 2}` {
+		t.Errorf("duplicate key changed order. got=%s", got)
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedStart   string
+	}{
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION", "1:1"},
+		{`{fn(x) { x }: 1}`, "unusable as hash key: FUNCTION", "1:2"},
+		{`{"a": 1, [1]: 2}`, "unusable as hash key: ARRAY", "1:10"},
+		{`{1.5: 1}`, "unusable as hash key: FLOAT", "1:2"},
+		{`{"a": x}`, "identifier not found: x", "1:7"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Span.Start.String() != tt.expectedStart {
+			t.Errorf("%s: wrong position. expected=%s, got=%s", tt.input, tt.expectedStart, errObj.Span.Start)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2},
+		{"let x = 5; x += 3; x", 8},
+		{"let x = 5; x -= 3; x", 2},
+		{"let x = 5; x *= 3; x", 15},
+		{"let x = 15; x /= 4; x", 3},
+		{"let x = 1.5; x *= 2; x", 3.0},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let a = 1; let b = 2; a = b = 7; a + b", 14},
+		// 赋值修改定义处的绑定, 闭包可以据此保存状态
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next(); next()", 3},
+		// 函数内的let遮蔽外层绑定, 之后的赋值不影响外层
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() * 10 + x", 31},
+		{"let f = fn(x) { x = x * 2; x }; let y = 4; f(y) + y", 12},
+		// 下标赋值原地修改数组和哈希表
+		{"let a = [1, 2, 3]; a[0] = 5; a[0]", 5},
+		{"let a = [1, 2, 3]; a[-1] = 9; a", "[1, 2, 9]"},
+		{"let a = [1, 2, 3]; a[1] += 10; a", "[1, 12, 3]"},
+		{"let a = [[1], [2]]; a[1][0] = 7; a", "[[1], [7]]"},
+		{"let a = [1]; let b = a; b[0] = 2; a[0]", 2},
+		{`let h = {}; h["k"] = 1; h["k"] += 1; h`, `{"k": 2}`},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] = 3; h`, `{"a": 3, "b": 2}`},
+		{"let a = [0, 0]; let f = fn(arr) { arr[0] = 1 }; f(a); a", "[1, 0]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			if f, ok := evaluated.(*object.Float); !ok || f.Value != expected {
+				t.Errorf("%s: expected %g, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s: expected %s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedCode    diagnostic.Code
+		expectedMessage string
+		expectedStart   string
+	}{
+		{"x = 1", diagnostic.UnknownIdentifier, "cannot assign to undeclared identifier: x", "1:1"},
+		{"let f = fn() { y += 1 }; f()", diagnostic.UnknownIdentifier, "cannot assign to undeclared identifier: y", "1:16"},
+		{"len = 1", diagnostic.UnknownIdentifier, "cannot assign to undeclared identifier: len", "1:1"},
+		{"let x = 1; x += true", diagnostic.TypeMismatch, "type mismatch: INTEGER + BOOLEAN", "1:12"},
+		{"let x = 1; x /= 0", diagnostic.DivisionByZero, "division by zero: 1 / 0", "1:12"},
+		{"let x = 1; x = y", diagnostic.UnknownIdentifier, "identifier not found: y", "1:16"},
+		{"let a = [1]; a[1] = 2", diagnostic.IndexOutOfRange, "index 1 out of range for ARRAY of length 1", "1:14"},
+		{"let a = [1]; a[2] += 2", diagnostic.IndexOutOfRange, "index 2 out of range for ARRAY of length 1", "1:14"},
+		{`let a = [1]; a["0"] = 2`, diagnostic.TypeMismatch, "index must be INTEGER, got STRING", "1:14"},
+		{`let s = "abc"; s[0] = "x"`, diagnostic.UnknownOperator, "index assignment not supported: STRING", "1:16"},
+		{"let h = {}; h[fn() {}] = 1", diagnostic.UnhashableKey, "unusable as hash key: FUNCTION", "1:13"},
+		{`let h = {}; h["k"] += 1`, diagnostic.TypeMismatch, "type mismatch: NULL + INTEGER", "1:13"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Code != tt.expectedCode {
+			t.Errorf("%s: wrong code. expected=%s, got=%s", tt.input, tt.expectedCode, errObj.Code)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Span.Start.String() != tt.expectedStart {
+			t.Errorf("%s: wrong position. expected=%s, got=%s", tt.input, tt.expectedStart, errObj.Span.Start)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; while (false) { i += 1 }; i", 0},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i }; sum", 15},
+		{"let i = 0; while (true) { i += 1; if (i == 7) { break } }; i", 7},
+		{"let i = 0; let odd = 0; while (i < 10) { i += 1; if (i % 2 == 0) { continue } odd += 1 }; odd", 5},
+		// 迭代不会消耗Go的调用栈
+		{"let i = 0; while (i < 100000) { i += 1 }; i", 100000},
+		// 循环体中的let只在当次循环中有效
+		{"let x = 1; let i = 0; while (i < 3) { let x = i * 10; i += 1 }; x", 1},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i > 3) { return i * 100 } } }; f()", 400},
+		// 嵌套循环中break只结束最内层循环
+		{"let n = 0; let i = 0; while (i < 3) { i += 1; while (true) { n += 1; break } }; n", 3},
+		// 表达式中的break和continue结束整个表达式, 不作为运算的操作数
+		{"let i = 0; let n = 0; while (i < 3) { i += 1; n += 1 + if (i == 2) { continue } else { i } }; n", 6},
+		{"let i = 0; while (true) { i += 1; let x = if (i == 3) { break } else { i } }; i", 3},
+		{"while (false) { 1 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else if evaluated != nil {
+			t.Errorf("%s: loop should have no value. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", 6},
+		{"let sum = 0; for (x in range(101)) { sum += x }; sum", 5050},
+		{"let sum = 0; for (x in []) { sum += 1 }; sum", 0},
+		{`let keys = ""; for (k in {"b": 1, "a": 2, "c": 3}) { keys += k }; keys`, "bac"},
+		{`let h = {"x": 2, "y": 3}; let p = 1; for (k in h) { p *= h[k] }; p`, 6},
+		{`let out = ""; for (c in "héllo") { out = c + out }; out`, "olléh"},
+		{"let n = 0; for (x in range(10)) { if (x == 4) { break } n += 1 }; n", 4},
+		{"let n = 0; for (x in range(10)) { if (x % 3 != 0) { continue } n += x }; n", 18},
+		{"let f = fn(xs) { for (x in xs) { if (x > 2) { return x } } 0 }; f([1, 5, 3])", 5},
+		{"let f = fn(xs) { for (x in xs) { if (x > 9) { return x } } 0 }; f([1, 5, 3])", 0},
+		// 循环变量遮蔽外层同名变量, 循环结束后外层的值不变
+		{"let x = 42; for (x in [1, 2]) { x }; x", 42},
+		// 每次循环的闭包捕获各自的循环变量
+		{"let fs = []; for (i in range(3)) { fs = push(fs, fn() { i * 10 }) }; fs[0]() + fs[2]()", 20},
+		// 遍历的是开始时的快照
+		{"let a = [1, 2]; let n = 0; for (x in a) { a[1] = 10; n += x }; n", 3},
+		{"let m = [[1, 2], [3]]; let s = 0; for (row in m) { for (v in row) { s += v } }; s", 6},
+		{"let s = 0; for (x in [1, 2, 3]) { s += [x, if (x == 2) { break } else { x }][1] }; s", 1},
+		{"let r = []; for (x in [1, 2]) { r = push(r, if (x == 1) { continue } else { x }) }; r[0]", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); !ok || str.Value != expected {
+				t.Errorf("%s: expected %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	errObj, ok := testEval("for (x in [1]) { 1 }; x").(*object.Error)
+	if !ok || errObj.Message != "identifier not found: x" {
+		t.Errorf("loop variable leaked out of the loop. got=%+v", errObj)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedCode    diagnostic.Code
+		expectedMessage string
+		expectedStart   string
+	}{
+		{"for (x in 5) { x }", diagnostic.NotIterable, "cannot iterate over INTEGER", "1:11"},
+		{"for (x in fn() {}) { x }", diagnostic.NotIterable, "cannot iterate over FUNCTION", "1:11"},
+		{"for (x in xs) { x }", diagnostic.UnknownIdentifier, "identifier not found: xs", "1:11"},
+		{"while (y) { 1 }", diagnostic.UnknownIdentifier, "identifier not found: y", "1:8"},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { i + true } }", diagnostic.TypeMismatch, "type mismatch: INTEGER + BOOLEAN", "1:49"},
+		{"for (x in [1, 2]) { x / (x - 2) }", diagnostic.DivisionByZero, "division by zero: 2 / 0", "1:21"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Code != tt.expectedCode {
+			t.Errorf("%s: wrong code. expected=%s, got=%s", tt.input, tt.expectedCode, errObj.Code)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong message. expected=%q, got=%q", tt.input, tt.expectedMessage, errObj.Message)
+		}
+		if errObj.Span.Start.String() != tt.expectedStart {
+			t.Errorf("%s: wrong position. expected=%s, got=%s", tt.input, tt.expectedStart, errObj.Span.Start)
+		}
+	}
+}
+
+func TestCustomBuiltins(t *testing.T) {
+	registry := builtins.NewRegistry(nil)
+	registry.Register(&builtins.Builtin{
+		Name: "double", MinArgs: 1, MaxArgs: 1, Params: []object.ObjectType{object.INTEGER_OBJ},
+		Fn: func(ctx *builtins.Context, args []object.Object) object.Object {
+			return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+		},
+	})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"double(21)", 42},
+		// 函数体和循环体中的环境使用同一个内置函数表
+		{"let f = fn(x) { double(x) }; let n = 0; for (i in [1, 2]) { n += f(i) }; n", 6},
+		{"let double = 1; double", 1},
+		// 只在指定的表中查找, 不回退到默认的内置函数
+		{"len([])", "identifier not found: len"},
+	}
+
+	for _, tt := range tests {
+		program := parser.Parse(lexer.Load(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvironmentWithBuiltins(registry))
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if err, ok := evaluated.(*object.Error); !ok || err.Message != expected {
+				t.Errorf("%s: expected error %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+
+	if err, ok := testEval("double(1)").(*object.Error); !ok || err.Code != diagnostic.UnknownIdentifier {
+		t.Errorf("custom builtin leaked into the default registry. got=%+v", err)
+	}
+}
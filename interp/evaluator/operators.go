@@ -0,0 +1,41 @@
+package evaluator
+
+import "go_interp/interp/object"
+
+// 以下函数供字节码虚拟机使用, 使两种执行方式遵循相同的运算规则并给出相同的错误信息.
+// 返回的错误还没有位置, 由调用者补上
+
+// EvalInfix 对两个值做二元运算; &&和||需要短路求值, 不在此处理
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// EvalPrefix 对一个值做一元运算
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// EvalIndex 取数组、字符串或哈希表的下标
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// EvalSlice 对数组或字符串切片, low和high为nil表示省略
+func EvalSlice(left, low, high object.Object) object.Object {
+	return evalSlice(left, low, high)
+}
+
+// EvalIndexAssignment 原地修改数组元素或哈希表的键值
+func EvalIndexAssignment(left, index, val object.Object) object.Object {
+	return evalIndexAssignment(left, index, val)
+}
+
+// IterationItems 返回for循环依次绑定到循环变量的值, 不可遍历时返回错误
+func IterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterationItems(iterable)
+}
+
+// IsTruthy null和false为假, 其余值均为真
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
@@ -0,0 +1,58 @@
+package lexer
+
+import (
+	"go_interp/interp/diagnostic"
+	"go_interp/model/token"
+)
+
+// Option 创建词法分析器时的可选配置
+type Option func(*Lexer)
+
+// WithComments makes NextToken return COMMENT tokens instead of skipping
+// comments, so that tools such as formatters can keep them.
+func WithComments() Option {
+	return func(I *Lexer) {
+		I.emitComments = true
+	}
+}
+
+// atComment 当前位置是否为注释的开头("//"或"/*")
+func (I *Lexer) atComment() bool {
+	return I.ch == '/' && (I.peekChar() == '/' || I.peekChar() == '*')
+}
+
+// readComment 读取一个注释, Literal包括注释符号本身. 块注释可以嵌套,
+// 未结束的块注释返回ILLEGAL
+func (I *Lexer) readComment() token.Token {
+	start := I.position
+	startPos := I.pos()
+
+	if I.peekChar() == '/' {
+		for I.ch != '\n' && I.ch != 0 {
+			I.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: I.input[start:I.position]}
+	}
+
+	depth := 0
+	for I.ch != 0 {
+		if I.ch == '/' && I.peekChar() == '*' {
+			depth++
+			I.readChar()
+		} else if I.ch == '*' && I.peekChar() == '/' {
+			depth--
+			I.readChar()
+			if depth == 0 {
+				I.readChar()
+				return token.Token{Type: token.COMMENT, Literal: I.input[start:I.position]}
+			}
+		}
+		I.readChar()
+	}
+
+	endPos := startPos
+	endPos.Offset += 2
+	endPos.Column += 2
+	I.report(diagnostic.UnterminatedComment, token.Span{Start: startPos, End: endPos}, "unterminated block comment")
+	return token.Token{Type: token.ILLEGAL, Literal: I.input[start:I.position]}
+}
@@ -1,57 +1,191 @@
 package lexer
 
 import (
+	"fmt"
+	"unicode/utf8"
+
+	"go_interp/interp/diagnostic"
 	"go_interp/model/token"
 	"go_interp/util"
 )
 
 type Lexer struct {
-	input string
+	input    string
+	filename string
 	// 所输入字符串中的当前位置(指向当前字符串)
 	position int
 	// 所输入字符串中的当前读取位置(指向当前字符只有的一个字符)
 	readPosition int
-	ch           byte
+	// 当前字符, 按UTF-8解码; 非法编码的字节为utf8.RuneError
+	ch rune
+	// 当前字符所在的行和列, 从1开始
+	line   int
+	column int
+	// base input[0]在整个源文件中的字节偏移, 用于解析字符串插值等嵌入的源码片段
+	base int
+	// emitComments 为true时注释作为COMMENT词法单元返回, 否则跳过
+	emitComments bool
+
+	diagnostics diagnostic.List
+}
+
+func Load(input string, opts ...Option) *Lexer {
+	return LoadFile("", input, opts...)
 }
 
-func Load(input string) *Lexer {
+// LoadFile is like Load, but records filename in the position of every token.
+func LoadFile(filename, input string, opts ...Option) *Lexer {
 	I := &Lexer{
-		input: input,
+		input:    input,
+		filename: filename,
+		line:     1,
+	}
+	for _, opt := range opts {
+		opt(I)
 	}
 	I.readChar()
 	return I
 }
 
+// LoadAt creates a lexer for a fragment of a larger source file, such as the
+// expression of a string interpolation, which starts at pos.
+func LoadAt(input string, pos token.Pos, opts ...Option) *Lexer {
+	I := &Lexer{
+		input:    input,
+		filename: pos.Filename,
+		line:     pos.Line,
+		column:   pos.Column - 1,
+		base:     pos.Offset,
+	}
+	for _, opt := range opts {
+		opt(I)
+	}
+	I.readChar()
+	return I
+}
+
+// Diagnostics returns the errors found so far, such as illegal characters or unterminated strings.
+func (I *Lexer) Diagnostics() diagnostic.List {
+	return I.diagnostics
+}
+
+// DiagnosticFor returns the first error reported inside tok, or nil.
+func (I *Lexer) DiagnosticFor(tok token.Token) *diagnostic.Diagnostic {
+	for _, d := range I.diagnostics {
+		offset := d.Span.Start.Offset
+		if offset >= tok.Pos.Offset && (offset < tok.End.Offset || offset == tok.Pos.Offset) {
+			return d
+		}
+	}
+	return nil
+}
+
+func (I *Lexer) report(code diagnostic.Code, span token.Span, format string, a ...interface{}) {
+	I.diagnostics = append(I.diagnostics, &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+	})
+}
+
+// Advance returns the position reached after reading text starting at pos.
+func Advance(pos token.Pos, text string) token.Pos {
+	for _, r := range text {
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	pos.Offset += len(text)
+	return pos
+}
+
 func (I *Lexer) readChar() {
+	if I.ch == '\n' {
+		I.line += 1
+		I.column = 0
+	}
+	I.position = I.readPosition
+	I.column += 1
 	if I.readPosition >= len(I.input) {
 		// 0是NUL字符的ASCII编码, 用来表示"尚未读取任何内容" 或 EOF
 		I.ch = 0
-	} else {
-		I.ch = I.input[I.readPosition]
+		return
+	}
+
+	// 列号按字符(rune)计数, 而位置偏移仍按字节计数
+	r, width := utf8.DecodeRuneInString(I.input[I.readPosition:])
+	I.ch = r
+	I.readPosition += width
+	if r == utf8.RuneError && width == 1 {
+		start := I.pos()
+		end := start
+		end.Offset++
+		end.Column++
+		I.report(diagnostic.InvalidUTF8, token.Span{Start: start, End: end},
+			"invalid UTF-8 encoding (byte %#02x)", I.input[I.position])
 	}
-	I.position = I.readPosition
-	I.readPosition += 1
 }
 
-func (I *Lexer) peekChar() byte {
+// invalidChar 当前字符是否为非法的UTF-8编码, 此时readChar已经报告过错误
+func (I *Lexer) invalidChar() bool {
+	return I.ch == utf8.RuneError && I.readPosition-I.position == 1
+}
+
+// pos 当前字符的位置
+func (I *Lexer) pos() token.Pos {
+	return token.Pos{
+		Filename: I.filename,
+		Offset:   I.base + I.position,
+		Line:     I.line,
+		Column:   I.column,
+	}
+}
+
+func (I *Lexer) peekChar() rune {
 	if I.readPosition >= len(I.input) {
 		return 0
 	} else {
-		return I.input[I.readPosition]
+		r, _ := utf8.DecodeRuneInString(I.input[I.readPosition:])
+		return r
 	}
 }
 
+// readIdentifier 标识符以字母或下划线开头, 之后可以包含数字
 func (I *Lexer) readIdentifier() (str string) {
 	position := I.position
-	for util.IsLetter(I.ch) {
+	for util.IsLetter(I.ch) || util.IsDigit(I.ch) {
 		I.readChar()
 	}
 	return I.input[position:I.position]
 }
 
-func (I *Lexer) NextToken() (tok token.Token) {
+func (I *Lexer) NextToken() token.Token {
 	I.skipWhiteSpace()
 
+	// 默认跳过注释; 未结束的块注释总是作为ILLEGAL返回, 以便语法分析报告错误
+	for I.atComment() {
+		start := I.pos()
+		tok := I.readComment()
+		tok.Pos = start
+		tok.End = I.pos()
+		if I.emitComments || tok.Type == token.ILLEGAL {
+			return tok
+		}
+		I.skipWhiteSpace()
+	}
+
+	start := I.pos()
+	tok := I.scanToken()
+	tok.Pos = start
+	tok.End = I.pos()
+	return tok
+}
+
+func (I *Lexer) scanToken() (tok token.Token) {
 	switch I.ch {
 	case '=':
 		if I.peekChar() == '=' {
@@ -66,9 +200,9 @@ func (I *Lexer) NextToken() (tok token.Token) {
 			tok = token.NewToken(token.ASSIGN, I.ch)
 		}
 	case '+':
-		tok = token.NewToken(token.PLUS, I.ch)
+		tok = I.readOperator(token.PLUS, map[rune]token.TokenType{'=': token.PLUS_ASSIGN})
 	case '-':
-		tok = token.NewToken(token.MINUS, I.ch)
+		tok = I.readOperator(token.MINUS, map[rune]token.TokenType{'=': token.MINUS_ASSIGN})
 	case '!':
 		if I.peekChar() == '=' {
 			ch := I.ch
@@ -82,13 +216,23 @@ func (I *Lexer) NextToken() (tok token.Token) {
 			tok = token.NewToken(token.BANG, I.ch)
 		}
 	case '<':
-		tok = token.NewToken(token.LT, I.ch)
+		tok = I.readOperator(token.LT, map[rune]token.TokenType{'=': token.LT_EQ, '<': token.SHL})
 	case '>':
-		tok = token.NewToken(token.GT, I.ch)
+		tok = I.readOperator(token.GT, map[rune]token.TokenType{'=': token.GT_EQ, '>': token.SHR})
+	case '&':
+		tok = I.readOperator(token.BIT_AND, map[rune]token.TokenType{'&': token.AND})
+	case '|':
+		tok = I.readOperator(token.BIT_OR, map[rune]token.TokenType{'|': token.OR})
+	case '^':
+		tok = token.NewToken(token.BIT_XOR, I.ch)
+	case '~':
+		tok = token.NewToken(token.BIT_NOT, I.ch)
 	case '*':
-		tok = token.NewToken(token.ASTERISK, I.ch)
+		tok = I.readOperator(token.ASTERISK, map[rune]token.TokenType{'=': token.ASTERISK_ASSIGN})
 	case '/':
-		tok = token.NewToken(token.SLASH, I.ch)
+		tok = I.readOperator(token.SLASH, map[rune]token.TokenType{'=': token.SLASH_ASSIGN})
+	case '%':
+		tok = token.NewToken(token.PERCENT, I.ch)
 	case '(':
 		tok = token.NewToken(token.LPAREN, I.ch)
 	case ')':
@@ -97,27 +241,44 @@ func (I *Lexer) NextToken() (tok token.Token) {
 		tok = token.NewToken(token.LBRACE, I.ch)
 	case '}':
 		tok = token.NewToken(token.RBRACE, I.ch)
+	case '[':
+		tok = token.NewToken(token.LBRACKET, I.ch)
+	case ']':
+		tok = token.NewToken(token.RBRACKET, I.ch)
+	case ':':
+		tok = token.NewToken(token.COLON, I.ch)
 	case ';':
 		tok = token.NewToken(token.SEMICOLON, I.ch)
 	case ',':
 		tok = token.NewToken(token.COMMA, I.ch)
+	case '"':
+		return I.readString()
 	case 0:
+		// EOF时不再前进, 保证EOF词法单元的位置停在输入末尾
 		tok.Literal = ""
 		tok.Type = token.EOF
+		return
 	default:
 		if util.IsLetter(I.ch) {
 			// 处理关键字和变量
 			tok.Literal = I.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return
-		} else if util.IsDigit(I.ch) {
+		} else if util.IsDecimal(I.ch) {
 			// 处理数字
-			tok.Type = token.INT
-			tok.Literal = I.readNumber()
-			return
+			return I.readNumber()
 		} else {
 			// 处理错误
 			tok = token.NewToken(token.ILLEGAL, I.ch)
+			if I.invalidChar() {
+				tok.Literal = I.input[I.position:I.readPosition]
+				break
+			}
+			start := I.pos()
+			end := start
+			end.Offset = I.base + I.readPosition
+			end.Column++
+			I.report(diagnostic.IllegalCharacter, token.Span{Start: start, End: end}, "illegal character %q", I.ch)
 		}
 	}
 
@@ -125,16 +286,19 @@ func (I *Lexer) NextToken() (tok token.Token) {
 	return
 }
 
-func (I *Lexer) skipWhiteSpace() {
-	for I.ch == ' ' || I.ch == '\t' || I.ch == '\n' || I.ch == '\r' {
+// readOperator 若下一个字符在pairs中, 则与当前字符组成双字符运算符, 否则为单字符运算符single.
+// 返回时I.ch仍指向运算符的最后一个字符
+func (I *Lexer) readOperator(single token.TokenType, pairs map[rune]token.TokenType) token.Token {
+	if tokenType, ok := pairs[I.peekChar()]; ok {
+		ch := I.ch
 		I.readChar()
+		return token.Token{Type: tokenType, Literal: string(ch) + string(I.ch)}
 	}
+	return token.NewToken(single, I.ch)
 }
 
-func (I *Lexer) readNumber() string {
-	position := I.position
-	for util.IsDigit(I.ch) {
+func (I *Lexer) skipWhiteSpace() {
+	for I.ch == ' ' || I.ch == '\t' || I.ch == '\n' || I.ch == '\r' {
 		I.readChar()
 	}
-	return I.input[position:I.position]
 }
@@ -111,7 +111,7 @@ func TestNextToken3(t *testing.T) {
 				x+y;
 			  };
 	          let result = add(five, ten);
-              !-/*5;
+              !-/ *5;
               5 < 10 > 5;
 `
 	tests := []struct {
@@ -191,7 +191,7 @@ func TestNextToken4(t *testing.T) {
 				x+y;
 			  };
 	          let result = add(five, ten);
-              !-/*5;
+              !-/ *5;
               5 < 10 > 5;
               if (5 < 10) { 
                 return true; 
@@ -293,7 +293,7 @@ func TestNextToken5(t *testing.T) {
 				x+y;
 			  };
 	          let result = add(five, ten);
-              !-/*5;
+              !-/ *5;
               5 < 10 > 5;
               if (5 < 10) { 
                 return true; 
@@ -396,3 +396,499 @@ func TestNextToken5(t *testing.T) {
 		}
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + 10"
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  string
+		expectedEnd  int
+	}{
+		{token.LET, "test.mk:1:1", 3},
+		{token.IDENT, "test.mk:1:5", 5},
+		{token.ASSIGN, "test.mk:1:7", 7},
+		{token.INT, "test.mk:1:9", 9},
+		{token.SEMICOLON, "test.mk:1:10", 10},
+		{token.IDENT, "test.mk:2:3", 14},
+		{token.PLUS, "test.mk:2:5", 16},
+		{token.INT, "test.mk:2:7", 19},
+		{token.EOF, "test.mk:2:9", 19},
+	}
+
+	I := LoadFile("test.mk", input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("%d. tok.Pos = %q, want %q", i, tok.Pos, tt.expectedPos)
+		}
+
+		if tok.End.Offset != tt.expectedEnd {
+			t.Errorf("%d. tok.End.Offset = %d, want %d", i, tok.End.Offset, tt.expectedEnd)
+		}
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	input := `"foobar" "foo bar" "a\nb\t\"c\"\\" "\u{4F60}\u{1F600}" "x = ${x + 1}!" "\${not}" ""`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.STRING, "a\nb\t\"c\"\\"},
+		{token.STRING, "你😀"},
+		{token.INTERP_STRING, `"x = ${x + 1}!"`},
+		{token.STRING, "${not}"},
+		{token.STRING, ""},
+		{token.EOF, ""},
+	}
+
+	I := Load(input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+
+	if len(I.Diagnostics()) != 0 {
+		t.Errorf("unexpected diagnostics: %v", I.Diagnostics().Messages())
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+		expectedEnd   int
+	}{
+		{"let s = \"abc;\nlet t = 1;", "1:9: unterminated string literal", 9},
+		{`"a\qb"`, "1:3: unknown escape sequence \\q", 4},
+		{`"\u{110000}"`, "1:2: invalid unicode escape \\u{110000}, not a valid code point", 11},
+		{`"\u0041"`, "1:2: invalid unicode escape, expected \\u{XXXX}", 3},
+		{`"sum: ${a + b`, "1:7: unterminated interpolation in string literal, expected }", 8},
+	}
+
+	for _, tt := range tests {
+		I := Load(tt.input)
+		for tok := I.NextToken(); tok.Type != token.EOF; tok = I.NextToken() {
+		}
+
+		diags := I.Diagnostics()
+		if len(diags) != 1 {
+			t.Errorf("input %q: expected 1 diagnostic, got=%d (%v)", tt.input, len(diags), diags.Messages())
+			continue
+		}
+		if diags[0].Error() != tt.expectedError {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, diags[0].Error())
+		}
+		if diags[0].Span.End.Offset != tt.expectedEnd {
+			t.Errorf("input %q: wrong span end. expected=%d, got=%d", tt.input, tt.expectedEnd, diags[0].Span.End.Offset)
+		}
+	}
+}
+
+func TestSplitString(t *testing.T) {
+	parts, err := SplitString(`"a${x}b${ f("}") }"`)
+	if err != nil {
+		t.Fatalf("SplitString() returned error: %v", err)
+	}
+
+	expected := []StringPart{
+		{Value: "a"},
+		{Value: "x", IsExpr: true, Offset: 4},
+		{Value: "b"},
+		{Value: ` f("}") `, IsExpr: true, Offset: 9},
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d (%+v)", len(expected), len(parts), parts)
+	}
+	for i, part := range parts {
+		if part != expected[i] {
+			t.Errorf("%d. part = %+v, want %+v", i, part, expected[i])
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := "let 变量 = café1 + x_2;\nλ; \"né\" ü"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "变量", "1:5"},
+		{token.ASSIGN, "=", "1:8"},
+		{token.IDENT, "café1", "1:10"},
+		{token.PLUS, "+", "1:16"},
+		{token.IDENT, "x_2", "1:18"},
+		{token.SEMICOLON, ";", "1:21"},
+		{token.IDENT, "λ", "2:1"},
+		{token.SEMICOLON, ";", "2:2"},
+		{token.STRING, "né", "2:4"},
+		{token.IDENT, "ü", "2:9"},
+		{token.EOF, "", "2:10"},
+	}
+
+	I := Load(input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("%d. tok.Pos = %q, want %q", i, tok.Pos, tt.expectedPos)
+		}
+	}
+
+	if len(I.Diagnostics()) != 0 {
+		t.Errorf("unexpected diagnostics: %v", I.Diagnostics().Messages())
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	input := "let a\xff = 1; \"b\xfe\"; @"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "a"},
+		{token.ILLEGAL, "\xff"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "\"b\xfe\""},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "@"},
+		{token.EOF, ""},
+	}
+
+	I := Load(input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+
+	expected := []string{
+		"1:6: invalid UTF-8 encoding (byte 0xff)",
+		"1:15: invalid UTF-8 encoding (byte 0xfe)",
+		"1:19: illegal character '@'",
+	}
+	diags := I.Diagnostics().Messages()
+	if len(diags) != len(expected) {
+		t.Fatalf("wrong number of diagnostics. expected=%d, got=%d (%q)", len(expected), len(diags), diags)
+	}
+	for i, msg := range expected {
+		if diags[i] != msg {
+			t.Errorf("diagnostic %d wrong. expected=%q, got=%q", i, msg, diags[i])
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing
+/* block /* nested */ still comment */ x / 2 * 3;
+/**/`
+
+	skipped := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.ASTERISK, "*"},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	I := Load(input)
+	for i, tt := range skipped {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+
+	emitted := []struct {
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{"// leading comment", "1:1"},
+		{"// trailing", "2:12"},
+		{"/* block /* nested */ still comment */", "3:1"},
+		{"/**/", "4:1"},
+	}
+
+	I = Load(input, WithComments())
+	var comments []token.Token
+	for tok := I.NextToken(); tok.Type != token.EOF; tok = I.NextToken() {
+		if tok.Type == token.COMMENT {
+			comments = append(comments, tok)
+		}
+	}
+
+	if len(comments) != len(emitted) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(emitted), len(comments))
+	}
+	for i, tt := range emitted {
+		if comments[i].Literal != tt.expectedLiteral {
+			t.Errorf("%d. comment.Literal = %q, want %q", i, comments[i].Literal, tt.expectedLiteral)
+		}
+		if comments[i].Pos.String() != tt.expectedPos {
+			t.Errorf("%d. comment.Pos = %q, want %q", i, comments[i].Pos, tt.expectedPos)
+		}
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	input := "let x = 1; /* outer /* inner */ x"
+
+	I := Load(input)
+	tests := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.ILLEGAL, token.EOF}
+	for i, expected := range tests {
+		tok := I.NextToken()
+		if tok.Type != expected {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, expected)
+		}
+	}
+
+	diags := I.Diagnostics()
+	if len(diags) != 1 || diags[0].Error() != "1:12: unterminated block comment" {
+		t.Errorf("wrong diagnostics. got=%q", diags.Messages())
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `0 42 1_000_000 0x1F 0XdeadBEEF 0x_ff 0o17 0O7_7 0b1010 0B1_0 3.14 0.5 1e-9 2.5E+3 1_0.0_1 7e3`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0x1F"},
+		{token.INT, "0XdeadBEEF"},
+		{token.INT, "0x_ff"},
+		{token.INT, "0o17"},
+		{token.INT, "0O7_7"},
+		{token.INT, "0b1010"},
+		{token.INT, "0B1_0"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.FLOAT, "1_0.0_1"},
+		{token.FLOAT, "7e3"},
+		{token.EOF, ""},
+	}
+
+	I := Load(input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+
+	if len(I.Diagnostics()) != 0 {
+		t.Errorf("unexpected diagnostics: %v", I.Diagnostics().Messages())
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"0x", "0x", "1:1: hexadecimal literal has no digits"},
+		{"0b102;", "0b102", "1:1: invalid digit '2' in binary literal"},
+		{"0o8", "0o8", "1:1: invalid digit '8' in octal literal"},
+		{"0xfg", "0xfg", "1:1: invalid digit 'g' in hexadecimal literal"},
+		{"1__000", "1__000", "1:1: '_' must separate successive digits"},
+		{"100_", "100_", "1:1: '_' must separate successive digits"},
+		{"1e+", "1e+", "1:1: exponent has no digits"},
+		{"12abc", "12abc", "1:1: invalid character 'a' in number literal"},
+		{"0123", "0123", "1:1: invalid leading zero in decimal literal, use 0o for octal"},
+		{"1.5.3", "1.5.3", "1:1: invalid character '.' in number literal"},
+	}
+
+	for _, tt := range tests {
+		I := Load(tt.input)
+		tok := I.NextToken()
+
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("input %q: tok.Type = %q, want %q", tt.input, tok.Type, token.ILLEGAL)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("input %q: tok.Literal = %q, want %q", tt.input, tok.Literal, tt.expectedLiteral)
+		}
+
+		diags := I.Diagnostics()
+		if len(diags) != 1 || diags[0].Error() != tt.expectedError {
+			t.Errorf("input %q: wrong diagnostics. expected=%q, got=%q", tt.input, tt.expectedError, diags.Messages())
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := `a <= b >= c < d > e && f || g % h & i | j ^ k << l >> m ~n`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.LT, "<"},
+		{token.IDENT, "d"},
+		{token.GT, ">"},
+		{token.IDENT, "e"},
+		{token.AND, "&&"},
+		{token.IDENT, "f"},
+		{token.OR, "||"},
+		{token.IDENT, "g"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "h"},
+		{token.BIT_AND, "&"},
+		{token.IDENT, "i"},
+		{token.BIT_OR, "|"},
+		{token.IDENT, "j"},
+		{token.BIT_XOR, "^"},
+		{token.IDENT, "k"},
+		{token.SHL, "<<"},
+		{token.IDENT, "l"},
+		{token.SHR, ">>"},
+		{token.IDENT, "m"},
+		{token.BIT_NOT, "~"},
+		{token.IDENT, "n"},
+		{token.EOF, ""},
+	}
+
+	I := Load(input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+}
+
+func TestBracketTokens(t *testing.T) {
+	input := `[1, 2][0] a[1:]`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "a"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	I := Load(input)
+	for i, tt := range tests {
+		tok := I.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt.expectedType)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%d. tok.Literal = %q, want %q", i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+}
+
+func TestAssignTokens(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x == x; -x; a+=-1`
+	expected := []token.TokenType{
+		token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.PLUS_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.MINUS_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.ASTERISK_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASH_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.EQ, token.IDENT, token.SEMICOLON,
+		token.MINUS, token.IDENT, token.SEMICOLON,
+		token.IDENT, token.PLUS_ASSIGN, token.MINUS, token.INT,
+		token.EOF,
+	}
+
+	I := Load(input)
+	for i, tt := range expected {
+		tok := I.NextToken()
+		if tok.Type != tt {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := `while for in break continue input format`
+	expected := []token.TokenType{
+		token.WHILE, token.FOR, token.IN, token.BREAK, token.CONTINUE, token.IDENT, token.IDENT, token.EOF,
+	}
+
+	I := Load(input)
+	for i, tt := range expected {
+		tok := I.NextToken()
+		if tok.Type != tt {
+			t.Errorf("%d. tok.Type = %q, want %q", i, tok.Type, tt)
+		}
+	}
+}
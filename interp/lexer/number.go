@@ -0,0 +1,128 @@
+package lexer
+
+import (
+	"fmt"
+
+	"go_interp/interp/diagnostic"
+	"go_interp/model/token"
+	"go_interp/util"
+)
+
+// numberBase 带前缀的整数字面量的进制信息
+type numberBase struct {
+	name    string
+	isDigit func(rune) bool
+}
+
+var numberBases = map[rune]numberBase{
+	'x': {"hexadecimal", isHex},
+	'X': {"hexadecimal", isHex},
+	'o': {"octal", isOctal},
+	'O': {"octal", isOctal},
+	'b': {"binary", isBinary},
+	'B': {"binary", isBinary},
+}
+
+func isHex(ch rune) bool {
+	return util.IsDecimal(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func isOctal(ch rune) bool {
+	return ch >= '0' && ch <= '7'
+}
+
+func isBinary(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
+// readNumber 读取数字字面量:
+//
+//	十进制   123  1_000_000
+//	十六进制 0x1F, 八进制 0o17, 二进制 0b1010
+//	浮点数   3.14  1e-9  2.5E+3
+//
+// 格式错误的字面量整体作为一个ILLEGAL词法单元返回, 避免后续产生连锁错误
+func (I *Lexer) readNumber() token.Token {
+	start := I.position
+	startPos := I.pos()
+	tokenType := token.INT
+	var errMsg string
+
+	if base, ok := numberBases[I.peekChar()]; ok && I.ch == '0' {
+		I.readChar()
+		I.readChar()
+		digits, msg := I.readDigits(base.isDigit, true)
+		errMsg = msg
+		if errMsg == "" && (util.IsLetter(I.ch) || util.IsDigit(I.ch)) {
+			errMsg = fmt.Sprintf("invalid digit %q in %s literal", I.ch, base.name)
+		}
+		if digits == 0 && errMsg == "" {
+			errMsg = fmt.Sprintf("%s literal has no digits", base.name)
+		}
+	} else {
+		_, errMsg = I.readDigits(util.IsDecimal, false)
+
+		if I.ch == '.' && util.IsDecimal(I.peekChar()) && errMsg == "" {
+			tokenType = token.FLOAT
+			I.readChar()
+			_, errMsg = I.readDigits(util.IsDecimal, false)
+		}
+
+		if (I.ch == 'e' || I.ch == 'E') && errMsg == "" {
+			tokenType = token.FLOAT
+			I.readChar()
+			if I.ch == '+' || I.ch == '-' {
+				I.readChar()
+			}
+			var digits int
+			digits, errMsg = I.readDigits(util.IsDecimal, false)
+			if digits == 0 && errMsg == "" {
+				errMsg = "exponent has no digits"
+			}
+		}
+
+		if errMsg == "" && (util.IsLetter(I.ch) || util.IsDigit(I.ch) || (I.ch == '.' && util.IsDecimal(I.peekChar()))) {
+			errMsg = fmt.Sprintf("invalid character %q in number literal", I.ch)
+		}
+		lit := I.input[start:I.position]
+		if errMsg == "" && tokenType == token.INT && len(lit) > 1 && lit[0] == '0' {
+			errMsg = "invalid leading zero in decimal literal, use 0o for octal"
+		}
+	}
+
+	if errMsg != "" {
+		// 跳过字面量剩余的部分
+		for util.IsLetter(I.ch) || util.IsDigit(I.ch) || (I.ch == '.' && util.IsDecimal(I.peekChar())) {
+			I.readChar()
+		}
+		I.report(diagnostic.MalformedNumber, token.Span{Start: startPos, End: I.pos()}, "%s", errMsg)
+		return token.Token{Type: token.ILLEGAL, Literal: I.input[start:I.position]}
+	}
+	return token.Token{Type: tokenType, Literal: I.input[start:I.position]}
+}
+
+// readDigits 读取一串数字, 数字之间可以用'_'分隔. leadingUnderscore表示第一个数字之前是否允许'_'(如0x_1F).
+// 返回读到的数字个数, 以及'_'位置不合法时的错误信息
+func (I *Lexer) readDigits(isDigit func(rune) bool, leadingUnderscore bool) (int, string) {
+	digits := 0
+	prevUnderscore := false
+	var errMsg string
+
+	for isDigit(I.ch) || I.ch == '_' {
+		if I.ch == '_' {
+			if (digits == 0 && !leadingUnderscore) || prevUnderscore {
+				errMsg = "'_' must separate successive digits"
+			}
+			prevUnderscore = true
+		} else {
+			digits++
+			prevUnderscore = false
+		}
+		I.readChar()
+	}
+
+	if prevUnderscore && errMsg == "" {
+		errMsg = "'_' must separate successive digits"
+	}
+	return digits, errMsg
+}
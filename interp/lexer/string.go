@@ -0,0 +1,232 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go_interp/interp/diagnostic"
+	"go_interp/model/token"
+)
+
+// StringPart 字符串字面量中的一段: 已解码的普通文本, 或${...}中的表达式源码
+type StringPart struct {
+	Value  string
+	IsExpr bool
+	// Offset 表达式源码相对于字面量开头(即左引号)的字节偏移, 仅IsExpr时有意义
+	Offset int
+}
+
+// stringError 扫描字符串时发现的错误, [offset, end)为出错的源码范围
+type stringError struct {
+	code   diagnostic.Code
+	msg    string
+	offset int
+	end    int
+}
+
+func (e *stringError) Error() string {
+	return e.msg
+}
+
+// SplitString splits the source of a string literal, quotes included, into
+// its text and interpolation parts. Escape sequences in text parts are decoded.
+func SplitString(lit string) ([]StringPart, error) {
+	parts, _, err := scanString(lit, 0)
+	if err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// scanString 从src[start]处的左引号开始扫描一个字符串字面量, 返回各个片段以及右引号之后的位置.
+// 出错时仍尽量找到字符串的结尾, 以便词法分析从字符串之后继续
+func scanString(src string, start int) (parts []StringPart, end int, err *stringError) {
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, StringPart{Value: text.String()})
+			text.Reset()
+		}
+	}
+
+	i := start + 1
+	for {
+		if i >= len(src) {
+			return nil, len(src), &stringError{
+				code:   diagnostic.UnterminatedString,
+				msg:    "unterminated string literal",
+				offset: start,
+				end:    start + 1,
+			}
+		}
+
+		switch c := src[i]; {
+		case c == '"':
+			flush()
+			return parts, i + 1, err
+		case c == '\\':
+			s, next, escErr := decodeEscape(src, i)
+			if escErr != nil && err == nil {
+				err = escErr
+			}
+			text.WriteString(s)
+			i = next
+		case c == '$' && i+1 < len(src) && src[i+1] == '{':
+			flush()
+			exprEnd, exprErr := scanInterpolation(src, i)
+			if exprErr != nil {
+				return nil, exprEnd, exprErr
+			}
+			parts = append(parts, StringPart{
+				Value:  src[i+2 : exprEnd-1],
+				IsExpr: true,
+				Offset: i + 2 - start,
+			})
+			i = exprEnd
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+}
+
+// scanInterpolation 扫描src[start]处的"${", 返回与之匹配的'}'之后的位置.
+// 表达式中可以出现成对的花括号以及嵌套的字符串
+func scanInterpolation(src string, start int) (int, *stringError) {
+	depth := 1
+	i := start + 2
+	for i < len(src) {
+		switch src[i] {
+		case '"':
+			_, end, err := scanString(src, i)
+			if err != nil {
+				return end, err
+			}
+			i = end
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+		i++
+	}
+	return len(src), &stringError{
+		code:   diagnostic.UnterminatedString,
+		msg:    "unterminated interpolation in string literal, expected }",
+		offset: start,
+		end:    start + 2,
+	}
+}
+
+// decodeEscape 解码src[i]处以'\'开头的转义序列, 返回解码结果和转义序列之后的位置
+func decodeEscape(src string, i int) (string, int, *stringError) {
+	if i+1 >= len(src) {
+		return "", i + 1, nil // 交给调用方报告未结束的字符串
+	}
+
+	switch src[i+1] {
+	case 'n':
+		return "\n", i + 2, nil
+	case 't':
+		return "\t", i + 2, nil
+	case 'r':
+		return "\r", i + 2, nil
+	case '0':
+		return "\x00", i + 2, nil
+	case '"':
+		return "\"", i + 2, nil
+	case '\\':
+		return "\\", i + 2, nil
+	case '$':
+		return "$", i + 2, nil
+	case 'u':
+		return decodeUnicodeEscape(src, i)
+	}
+
+	_, size := utf8.DecodeRuneInString(src[i+1:])
+	return "", i + 1 + size, &stringError{
+		code:   diagnostic.InvalidEscape,
+		msg:    fmt.Sprintf("unknown escape sequence \\%s", src[i+1:i+1+size]),
+		offset: i,
+		end:    i + 1 + size,
+	}
+}
+
+// decodeUnicodeEscape 解码\u{XXXX}, 花括号中为1到6位十六进制数
+func decodeUnicodeEscape(src string, i int) (string, int, *stringError) {
+	invalid := func(end int, msg string) (string, int, *stringError) {
+		return "", end, &stringError{code: diagnostic.InvalidEscape, msg: msg, offset: i, end: end}
+	}
+
+	if i+2 >= len(src) || src[i+2] != '{' {
+		return invalid(i+2, "invalid unicode escape, expected \\u{XXXX}")
+	}
+	close := strings.IndexByte(src[i+3:], '}')
+	if close < 0 || strings.ContainsAny(src[i+3:i+3+close], "\"\n") {
+		return invalid(i+3, "invalid unicode escape, missing }")
+	}
+	end := i + 3 + close + 1
+	digits := src[i+3 : i+3+close]
+	if len(digits) == 0 || len(digits) > 6 {
+		return invalid(end, fmt.Sprintf("invalid unicode escape \\u{%s}, expected 1 to 6 hex digits", digits))
+	}
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return invalid(end, fmt.Sprintf("invalid unicode escape \\u{%s}, expected 1 to 6 hex digits", digits))
+	}
+	if !utf8.ValidRune(rune(code)) {
+		return invalid(end, fmt.Sprintf("invalid unicode escape \\u{%s}, not a valid code point", digits))
+	}
+	return string(rune(code)), end, nil
+}
+
+// readString 读取以当前字符'"'开头的字符串字面量, 返回后I.ch为右引号之后的字符
+func (I *Lexer) readString() token.Token {
+	start := I.position
+	parts, end, err := scanString(I.input, start)
+
+	errCount := len(I.diagnostics)
+	var errStart, errEnd token.Pos
+	for I.position < end && I.ch != 0 {
+		if err != nil && I.position == err.offset {
+			errStart = I.pos()
+		}
+		if err != nil && I.position == err.end {
+			errEnd = I.pos()
+		}
+		I.readChar()
+	}
+	raw := I.input[start:I.position]
+
+	if err != nil {
+		if !errStart.IsValid() {
+			errStart = I.pos()
+		}
+		if !errEnd.IsValid() {
+			errEnd = I.pos()
+		}
+		I.report(err.code, token.Span{Start: errStart, End: errEnd}, "%s", err.msg)
+		return token.Token{Type: token.ILLEGAL, Literal: raw}
+	}
+	if len(I.diagnostics) > errCount {
+		// 字符串中含有非法的UTF-8编码, readChar已经报告过错误
+		return token.Token{Type: token.ILLEGAL, Literal: raw}
+	}
+
+	for _, part := range parts {
+		if part.IsExpr {
+			return token.Token{Type: token.INTERP_STRING, Literal: raw}
+		}
+	}
+	var value strings.Builder
+	for _, part := range parts {
+		value.WriteString(part.Value)
+	}
+	return token.Token{Type: token.STRING, Literal: value.String()}
+}
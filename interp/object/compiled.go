@@ -0,0 +1,76 @@
+package object
+
+import (
+	"fmt"
+
+	"go_interp/interp/code"
+)
+
+const COMPILED_FUNCTION_OBJ ObjectType = "COMPILED_FUNCTION"
+
+// CompiledFunction 编译后的函数体, 保存在常量池中; 运行时由OpClosure包装为闭包
+type CompiledFunction struct {
+	// Name 函数通过let绑定的名字, 用于调用栈; 匿名函数和顶层程序为空
+	Name          string
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// Captures 创建闭包时依次捕获的变量, 在函数体中以OpGetFree的操作数引用
+	Captures []Capture
+	Lines    code.LineTable
+	// Source 函数字面量的源码, 闭包的Inspect()与求值器中的函数值一致
+	Source string
+}
+
+// Capture 闭包捕获的一个变量: Local为true时是外层函数的局部变量, 否则是外层闭包已捕获的变量
+type Capture struct {
+	Local bool
+	Index int
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure 运行时的函数值. 捕获的变量通过Upvalue共享, 闭包之间以及闭包与外层函数看到的是同一个变量
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Upvalue
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	return c.Fn.Source
+}
+
+// Upvalue 闭包捕获的变量. 外层函数返回之前, Location指向虚拟机栈上的局部变量;
+// 之后变量被移到Upvalue自身中
+type Upvalue struct {
+	Location *Object
+	closed   Object
+}
+
+func NewUpvalue(location *Object) *Upvalue {
+	return &Upvalue{Location: location}
+}
+
+func (u *Upvalue) Get() Object {
+	return *u.Location
+}
+
+func (u *Upvalue) Set(val Object) {
+	*u.Location = val
+}
+
+// Close 将变量从栈上移出, 之后栈上的槽位可以被复用
+func (u *Upvalue) Close() {
+	u.closed = *u.Location
+	u.Location = &u.closed
+}
@@ -0,0 +1,94 @@
+package object
+
+import "sort"
+
+// Environment 保存标识符与值的绑定. 每次函数调用都会创建一个新的环境,
+// 其outer指向函数定义时所在的环境, 从而实现词法作用域
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	// depth 环境所在的函数调用的嵌套层数, 顶层为0
+	depth int
+	// builtins 没有绑定的标识符在其中查找, 为nil时由求值器使用默认的内置函数表
+	builtins Builtins
+}
+
+// Builtins 内置函数表; builtins.Registry实现了它
+type Builtins interface {
+	Lookup(name string) (*Builtin, bool)
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnvironmentWithBuiltins 创建使用内置函数表b的顶层环境, 内层环境沿用同一个表
+func NewEnvironmentWithBuiltins(b Builtins) *Environment {
+	env := NewEnvironment()
+	env.builtins = b
+	return env
+}
+
+// NewEnclosedEnvironment creates an environment whose lookups fall back to outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	env.depth = outer.depth
+	env.builtins = outer.builtins
+	return env
+}
+
+// NewCallEnvironment 创建函数调用的环境: 查找时回退到函数定义时所在的环境outer,
+// 调用深度则是调用者的深度加1
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = caller.depth + 1
+	return env
+}
+
+// Builtins 返回环境使用的内置函数表, 没有指定时为nil
+func (e *Environment) Builtins() Builtins {
+	return e.builtins
+}
+
+// CallDepth 返回环境所在的函数调用的嵌套层数
+func (e *Environment) CallDepth() int {
+	return e.depth
+}
+
+// Get 先在当前环境中查找, 找不到时沿outer逐层向外查找
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set 总是在当前环境中绑定, 同名的外层绑定被遮蔽而不是被修改
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign 修改已有的绑定: 沿outer找到定义该名字的环境并在其中修改, 名字未定义时返回false
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names bound in e itself, not in its outer environments,
+// in alphabetical order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,106 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// HashKey 哈希表中键的标识, 类型和值都相同的键是同一个键.
+// 字符串键直接保存内容, 不会发生散列冲突
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	Text  string
+}
+
+// Hashable 可以作为哈希表键的值
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+// HashPair 保存原始的键, 用于输出和遍历
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash 哈希表, 按键第一次插入的顺序遍历和输出
+type Hash struct {
+	pairs map[HashKey]HashPair
+	keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+// Inspect 与数组相同, 包含自身时内层输出为{...}
+func (h *Hash) Inspect() string {
+	return h.inspect(map[Object]bool{})
+}
+
+func (h *Hash) inspect(visiting map[Object]bool) string {
+	if visiting[h] {
+		return "{...}"
+	}
+	visiting[h] = true
+	defer delete(visiting, h)
+
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.Pairs() {
+		pairs = append(pairs, inspectElement(pair.Key, visiting)+": "+inspectElement(pair.Value, visiting))
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// Set 已有的键保持原来的位置, 只替换值
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.pairs[hashKey]; !ok {
+		h.keys = append(h.keys, hashKey)
+	}
+	h.pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+func (h *Hash) Len() int {
+	return len(h.keys)
+}
+
+// Pairs returns the pairs in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.keys))
+	for i, key := range h.keys {
+		pairs[i] = h.pairs[key]
+	}
+	return pairs
+}
@@ -0,0 +1,274 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go_interp/interp/ast"
+	"go_interp/interp/diagnostic"
+	"go_interp/model/token"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      ObjectType = "INTEGER"
+	BOOLEAN_OBJ      ObjectType = "BOOLEAN"
+	NULL_OBJ         ObjectType = "NULL"
+	RETURN_VALUE_OBJ ObjectType = "RETURN_VALUE"
+	ERROR_OBJ        ObjectType = "ERROR"
+	STRING_OBJ       ObjectType = "STRING"
+	FLOAT_OBJ        ObjectType = "FLOAT"
+	FUNCTION_OBJ     ObjectType = "FUNCTION"
+	BUILTIN_OBJ      ObjectType = "BUILTIN"
+	ARRAY_OBJ        ObjectType = "ARRAY"
+	HASH_OBJ         ObjectType = "HASH"
+	BREAK_OBJ        ObjectType = "BREAK"
+	CONTINUE_OBJ     ObjectType = "CONTINUE"
+)
+
+// Object 求值过程中产生的所有值都实现该接口
+type Object interface {
+	Type() ObjectType
+	// Inspect returns the printable representation of the value.
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (i *Integer) Inspect() string {
+	return fmt.Sprintf("%d", i.Value)
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect 整数值的浮点数也保留小数点, 以便与Integer区分, 如3.0
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType {
+	return BOOLEAN_OBJ
+}
+
+func (b *Boolean) Inspect() string {
+	return fmt.Sprintf("%t", b.Value)
+}
+
+// 这些值只有一种可能, 全局复用同一个实例, 比较时直接比较指针即可
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
+// Null 表示"没有值"
+type Null struct{}
+
+func (n *Null) Type() ObjectType {
+	return NULL_OBJ
+}
+
+func (n *Null) Inspect() string {
+	return "null"
+}
+
+// ReturnValue 包装return语句的返回值, 用于在嵌套的语句中向外传递
+type ReturnValue struct {
+	Value Object
+}
+
+func (r *ReturnValue) Type() ObjectType {
+	return RETURN_VALUE_OBJ
+}
+
+func (r *ReturnValue) Inspect() string {
+	return r.Value.Inspect()
+}
+
+// Break 由break语句产生, 与ReturnValue一样向外传递, 直到所在的循环
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+// Continue 由continue语句产生, 向外传递到所在的循环后进入下一次循环
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// Error 运行时错误, 与ReturnValue一样会中断后续语句的求值
+type Error struct {
+	Message string
+	Code    diagnostic.Code
+	// Span 出错的AST节点在源码中的区间
+	Span token.Span
+	// Stack 出错时的调用栈, 最内层的调用在前
+	Stack []Frame
+}
+
+// Frame 调用栈中的一层: 正在执行的函数及调用它的位置
+type Frame struct {
+	Function string
+	Pos      token.Pos
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("in %s, called at %s", f.Function, f.Pos)
+}
+
+func (e *Error) Type() ObjectType {
+	return ERROR_OBJ
+}
+
+func (e *Error) Inspect() string {
+	return "ERROR: " + e.Message
+}
+
+// Diagnostic converts the error into a diagnostic with one note per stack frame,
+// so that it can be rendered like a syntax error.
+func (e *Error) Diagnostic() *diagnostic.Diagnostic {
+	d := &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     e.Code,
+		Message:  e.Message,
+		Span:     e.Span,
+	}
+	for _, frame := range e.Stack {
+		d.Notes = append(d.Notes, frame.String())
+	}
+	return d
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+
+// Function 函数值, Env为函数字面量求值时所在的环境, 函数体中的自由变量在其中查找
+type Function struct {
+	// Name 函数首次通过let绑定的名字, 用于调用栈; 匿名函数为空
+	Name       string
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+// BuiltinFunction 内置函数的Go实现
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function " + b.Name
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+// Inspect 字符串元素加引号, 以便与其他类型的元素区分, 如["a", 1].
+// 下标赋值可以使数组包含自身, 内层再次出现时输出为[...]
+func (a *Array) Inspect() string {
+	return a.inspect(map[Object]bool{})
+}
+
+// inspect visiting为正在输出的外层数组和哈希表
+func (a *Array) inspect(visiting map[Object]bool) string {
+	if visiting[a] {
+		return "[...]"
+	}
+	visiting[a] = true
+	defer delete(visiting, a)
+
+	var out bytes.Buffer
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, inspectElement(e, visiting))
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// inspectElement 数组和哈希表中的字符串加引号输出
+func inspectElement(obj Object, visiting map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *String:
+		return strconv.Quote(obj.Value)
+	case *Array:
+		return obj.inspect(visiting)
+	case *Hash:
+		return obj.inspect(visiting)
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,90 @@
+package object
+
+import "testing"
+
+func TestHashKeys(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+
+	tests := []struct {
+		a, b  Hashable
+		equal bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Integer{Value: -1}, &Integer{Value: -1}, true},
+		{TRUE, &Boolean{Value: true}, true},
+		{TRUE, FALSE, false},
+		{&Integer{Value: 1}, TRUE, false},
+		{&Integer{Value: 0}, FALSE, false},
+		{&Integer{Value: 1}, &String{Value: "1"}, false},
+		{&String{Value: ""}, &Integer{Value: 0}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.HashKey() == tt.b.HashKey(); got != tt.equal {
+			t.Errorf("%s %s == %s %s: got=%t, want=%t",
+				tt.a.Type(), tt.a.Inspect(), tt.b.Type(), tt.b.Inspect(), got, tt.equal)
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "z"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 3}, &String{Value: "three"})
+	h.Set(TRUE, NULL)
+	h.Set(&String{Value: "z"}, &Integer{Value: 2})
+
+	if h.Len() != 3 {
+		t.Fatalf("h.Len() = %d, want 3", h.Len())
+	}
+	if got := h.Inspect(); got != `{"z": 2, 3: "three", true: null}` {
+		t.Errorf("wrong Inspect(). got=%s", got)
+	}
+
+	value, ok := h.Get(&Integer{Value: 3})
+	if !ok || value.Inspect() != "three" {
+		t.Errorf("h.Get(3) = %v, %t", value, ok)
+	}
+	if _, ok := h.Get(&String{Value: "3"}); ok {
+		t.Errorf("h.Get(\"3\") found a value")
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}}}
+	a.Elements = append(a.Elements, a)
+
+	h := NewHash()
+	h.Set(&String{Value: "self"}, h)
+	h.Set(&String{Value: "a"}, a)
+
+	// 同一个数组在不同位置出现, 但不构成环时完整输出
+	shared := &Array{Elements: []Object{&String{Value: "x"}}}
+	pair := &Array{Elements: []Object{shared, shared}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{a, "[1, [...]]"},
+		{h, `{"self": {...}, "a": [1, [...]]}`},
+		{&Array{Elements: []Object{h}}, `[{"self": {...}, "a": [1, [...]]}]`},
+		{pair, `[["x"], ["x"]]`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect(). expected=%s, got=%s", tt.expected, got)
+		}
+	}
+}
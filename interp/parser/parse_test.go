@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"go_interp/interp/ast"
+	"go_interp/interp/diagnostic"
 	"go_interp/interp/lexer"
+	"go_interp/model/token"
 )
 
 // 二元表达式
@@ -79,6 +81,126 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3<5 == true",
 			"((3<5)==true)",
 		},
+		{
+			"1 + (2 + 3) + 4",
+			"((1+(2+3))+4)",
+		},
+		{
+			"(5 + 5) * 2",
+			"((5+5)*2)",
+		},
+		{
+			"-(5 + 5)",
+			"(-(5+5))",
+		},
+		{
+			"!(true == true)",
+			"(!(true==true))",
+		},
+		{
+			"a + add(b * c) + d",
+			"((a+add((b*c)))+d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2*3), (4+5), add(6, (7*8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a+b)+((c*d)/f))+g))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a<=b)==(c>=d))",
+		},
+		{
+			"a % b * c + d",
+			"(((a%b)*c)+d)",
+		},
+		{
+			"a || b && c",
+			"(a||(b&&c))",
+		},
+		{
+			"a && b || c && d",
+			"((a&&b)||(c&&d))",
+		},
+		{
+			"a == b && c != d",
+			"((a==b)&&(c!=d))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a|(b^(c&d)))",
+		},
+		{
+			"a & b == c",
+			"(a&(b==c))",
+		},
+		{
+			"a || b | c",
+			"(a||(b|c))",
+		},
+		{
+			"1 << 2 + 3",
+			"(1<<(2+3))",
+		},
+		{
+			"a < b << c",
+			"(a<(b<<c))",
+		},
+		{
+			"a >> 1 >> 2",
+			"((a>>1)>>2)",
+		},
+		{
+			"~a & ~b",
+			"((~a)&(~b))",
+		},
+		{
+			"-a % b",
+			"((-a)%b)",
+		},
+		{
+			"!a && !b",
+			"((!a)&&(!b))",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a*([1, 2, 3, 4][(b*c)]))*d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a*(b[2])), (b[1]), (2*([1, 2][1])))",
+		},
+		{
+			"-a[0]",
+			"(-(a[0]))",
+		},
+		{
+			"f(x)[0]",
+			"(f(x)[0])",
+		},
+		{
+			"a[1:n - 1][0]",
+			"((a[1:(n-1)])[0])",
+		},
+		{
+			"x = 1 + 2 * 3",
+			"(x=(1+(2*3)))",
+		},
+		{
+			"a = b = c || d",
+			"(a=(b=(c||d)))",
+		},
+		{
+			"a[i + 1] += f(x)",
+			"((a[(i+1)])+=f(x))",
+		},
+		{
+			"x -= y *= 2",
+			"(x-=(y*=2))",
+		},
 	}
 	for _, test := range tests {
 		l := lexer.Load(test.input)
@@ -115,6 +237,16 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"foobar < barfoo;", "foobar", "<", "barfoo"},
 		{"foobar == barfoo;", "foobar", "==", "barfoo"},
 		{"foobar != barfoo;", "foobar", "!=", "barfoo"},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -198,6 +330,11 @@ func TestParsingPrefixExpression(t *testing.T) {
 			operator:   "-",
 			integerVal: 10,
 		},
+		{
+			input:      "~7;",
+			operator:   "~",
+			integerVal: 7,
+		},
 	}
 	for _, tt := range prefixTests {
 		I := lexer.Load(tt.input)
@@ -362,50 +499,85 @@ func TestIntegerLiteralExpression(t *testing.T) {
 }
 
 func TestLetStatements(t *testing.T) {
-	input := `let x = 5; let y = 10; let foobar = 838383;`
-	I := lexer.Load(input)
-	p := Parse(I)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nil")
-	}
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements should 3 statements. got=%d", len(program.Statements))
-	}
 	tests := []struct {
+		input              string
 		expectedIdentifier string
+		expectedValue      interface{}
 	}{
-		{"x"},
-		{"y"},
-		{"foobar"},
+		{"let x = 5;", "x", 5},
+		{"let y = true;", "y", true},
+		{"let foobar = y;", "foobar", "y"},
+		{"let z = 10", "z", 10},
 	}
-	for i, tt := range tests {
-		stmt := program.Statements[i]
+
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements should 1 statements. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
 		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
 			return
 		}
+
+		val := stmt.(*ast.LetStatement).Value
+		if !testLiteralExpression(t, val, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestLetStatementValueTree(t *testing.T) {
+	input := `let x = 5 * y; let z = -a + b * c`
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements should 2 statements. got=%d", len(program.Statements))
+	}
+
+	first := program.Statements[0].(*ast.LetStatement)
+	if !testInfixExpression(t, first.Value, 5, "*", "y") {
+		return
+	}
+
+	second := program.Statements[1].(*ast.LetStatement)
+	if second.Value.String() != "((-a)+(b*c))" {
+		t.Errorf("second.Value.String() wrong. got=%q", second.Value.String())
 	}
 }
 
+// TestLetStatementsIllegal let之后缺少标识符时报错, 之前的let语句照常解析
 func TestLetStatementsIllegal(t *testing.T) {
 	input := `let x = 5; let y = 10; let 838383;`
 	I := lexer.Load(input)
 	p := Parse(I)
 	program := p.ParseProgram()
-	checkParserErrors(t, p)
 	if program == nil {
 		t.Fatalf("ParseProgram() returned nil")
 	}
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements should 3 statements. got=%d", len(program.Statements))
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+	expected := "1:28: expected next token to be IDENT, but got INT"
+	if errors[0] != expected {
+		t.Errorf("errors[0] wrong. expected=%q, got=%q", expected, errors[0])
 	}
+
 	tests := []struct {
 		expectedIdentifier string
 	}{
 		{"x"},
 		{"y"},
-		{"foobar"},
 	}
 	for i, tt := range tests {
 		stmt := program.Statements[i]
@@ -453,25 +625,55 @@ func testLetStatement(t *testing.T, stmt ast.Statement, expectedIdentifier strin
 }
 
 func TestReturnStatement(t *testing.T) {
-	input := `return 5; return 20; return 993 322;`
-	I := lexer.Load(input)
-	p := Parse(I)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
-
-	if len(program.Statements) != 3 {
-		t.Fatalf("program.Statements should 3 statements. got=%d", len(program.Statements))
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return true;", true},
+		{"return foobar;", "foobar"},
+		{"return 993322", 993322},
 	}
 
-	for _, stat := range program.Statements {
-		returnStmt, ok := stat.(*ast.ReturnStatement)
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements should 1 statements. got=%d", len(program.Statements))
+		}
+
+		returnStmt, ok := program.Statements[0].(*ast.ReturnStatement)
 		if !ok {
-			t.Fatalf("stmt not *ast.ReturnStatement, got=%T", stat)
+			t.Fatalf("stmt not *ast.ReturnStatement, got=%T", program.Statements[0])
 		}
 
 		if returnStmt.TokenLiteral() != "return" {
 			t.Fatalf("returnStmt.TokenLiteral() = %q, want %q", returnStmt.TokenLiteral(), "return")
 		}
+
+		if !testLiteralExpression(t, returnStmt.Value, tt.expectedValue) {
+			return
+		}
+	}
+}
+
+func TestReturnStatementValueTree(t *testing.T) {
+	input := `return a + b * 2`
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statements. got=%d", len(program.Statements))
+	}
+
+	returnStmt := program.Statements[0].(*ast.ReturnStatement)
+	if returnStmt.Value.String() != "(a+(b*2))" {
+		t.Errorf("returnStmt.Value.String() wrong. got=%q", returnStmt.Value.String())
 	}
 }
 
@@ -502,3 +704,948 @@ func TestIdentifierExpression(t *testing.T) {
 		t.Fatalf("ident.Name should be 'foobar'. got=%s", ident.Value)
 	}
 }
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x = 5;\nlet = 10;\n"
+	I := lexer.LoadFile("main.mk", input)
+	p := Parse(I)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+	expected := "main.mk:2:5: expected next token to be IDENT, but got ="
+	if errors[0] != expected {
+		t.Errorf("errors[0] wrong. expected=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let x = 1 + 2;\n-y * 3"
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []struct {
+		node          ast.Node
+		expectedPos   string
+		expectedEndAt int
+	}{
+		{program, "1:1", 21},
+		{program.Statements[0], "1:1", 13},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:9", 13},
+		{program.Statements[1], "2:1", 21},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression).Left, "2:1", 17},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedPos {
+			t.Errorf("%d. node.Pos() = %q, want %q", i, tt.node.Pos(), tt.expectedPos)
+		}
+		if tt.node.End().Offset != tt.expectedEndAt {
+			t.Errorf("%d. node.End().Offset = %d, want %d", i, tt.node.End().Offset, tt.expectedEndAt)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := "let x 5;"
+	I := lexer.Load(input)
+	p := Parse(I)
+	p.ParseProgram()
+
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		t.Fatalf("expected diagnostics, got none")
+	}
+
+	d := diags[0]
+	if d.Code != diagnostic.UnexpectedToken {
+		t.Errorf("d.Code wrong. expected=%q, got=%q", diagnostic.UnexpectedToken, d.Code)
+	}
+	if d.Expected != token.ASSIGN || d.Actual != token.INT {
+		t.Errorf("d.Expected/d.Actual wrong. got=%s/%s", d.Expected, d.Actual)
+	}
+	if d.Span.Start.Offset != 6 || d.Span.End.Offset != 7 {
+		t.Errorf("d.Span wrong. got=%d-%d", d.Span.Start.Offset, d.Span.End.Offset)
+	}
+	if p.Err() == nil {
+		t.Errorf("p.Err() should not be nil")
+	}
+}
+
+// 缺少名字时的提示取决于所在的语法结构
+func TestDiagnosticHints(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedHint string
+	}{
+		{"let = 1", letNameHint},
+		{"let x 5;", letAssignHint},
+		{"fn(1, 2) { 1 }", paramHint},
+		{"fn(a, 2) { 1 }", paramHint},
+		{"for (1 in [1]) { }", loopVarHint},
+		{"for x in xs { }", ""},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		p.ParseProgram()
+		diags := p.Diagnostics()
+		if len(diags) == 0 {
+			t.Errorf("%s: expected diagnostics, got none", tt.input)
+			continue
+		}
+		if diags[0].Hint != tt.expectedHint {
+			t.Errorf("%s: wrong hint. expected=%q, got=%q", tt.input, tt.expectedHint, diags[0].Hint)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			"let = 5; let y = 10; let z 15; y + z;",
+			[]string{
+				"1:5: expected next token to be IDENT, but got =",
+				"1:28: expected next token to be =, but got INT",
+			},
+			[]string{"let y = 10;", "(y+z)"},
+		},
+		{
+			"let x = * 5\nlet y = 2\nreturn ;\nx + y",
+			[]string{
+				"1:9: no prefix parse function for * found",
+				"3:8: no prefix parse function for ; found",
+			},
+			[]string{"let y = 2;", "(x+y)"},
+		},
+		{
+			"1 + ; 2 * ; 3",
+			[]string{
+				"1:5: no prefix parse function for ; found",
+				"1:11: no prefix parse function for ; found",
+			},
+			[]string{"3"},
+		},
+		{
+			"let 1 let 2 let 3",
+			[]string{
+				"1:5: expected next token to be IDENT, but got INT",
+				"1:11: expected next token to be IDENT, but got INT",
+				"1:17: expected next token to be IDENT, but got INT",
+			},
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("input %q: wrong number of errors. expected=%d, got=%d (%q)",
+				tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, expected := range tt.expectedErrors {
+			if errors[i] != expected {
+				t.Errorf("input %q: errors[%d] wrong. expected=%q, got=%q", tt.input, i, expected, errors[i])
+			}
+		}
+
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Errorf("input %q: wrong number of statements. expected=%d, got=%d",
+				tt.input, len(tt.expectedStatements), len(program.Statements))
+			continue
+		}
+		for i, expected := range tt.expectedStatements {
+			if program.Statements[i].String() != expected {
+				t.Errorf("input %q: statement %d wrong. expected=%q, got=%q",
+					tt.input, i, expected, program.Statements[i].String())
+			}
+		}
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ExpressionStatement, got=%T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.IfExpression, got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Consequence.Statements) != 1 {
+		t.Fatalf("consequence should 1 statement. got=%d", len(exp.Consequence.Statements))
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] not *ast.ExpressionStatement, got=%T", exp.Consequence.Statements[0])
+	}
+
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative was not nil. got=%+v", exp.Alternative)
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	input := `if (x < y) { x } else { let z = y; z }`
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.IfExpression, got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if exp.Alternative == nil {
+		t.Fatalf("exp.Alternative was nil")
+	}
+
+	if len(exp.Alternative.Statements) != 2 {
+		t.Fatalf("alternative should 2 statements. got=%d", len(exp.Alternative.Statements))
+	}
+
+	if !testLetStatement(t, exp.Alternative.Statements[0], "z") {
+		return
+	}
+
+	alternative, ok := exp.Alternative.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[1] not *ast.ExpressionStatement, got=%T", exp.Alternative.Statements[1])
+	}
+
+	if !testIdentifier(t, alternative.Expression, "z") {
+		return
+	}
+
+	if exp.End().Offset != len(input) {
+		t.Errorf("exp.End().Offset = %d, want %d", exp.End().Offset, len(input))
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+
+	function, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression not *ast.FunctionLiteral, got=%T", program.Statements[0])
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d", len(function.Parameters))
+	}
+
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements should 1 statement. got=%d", len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T", function.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+
+	if exp.End().Offset != len(input)-1 {
+		t.Errorf("exp.End().Offset = %d, want %d", exp.End().Offset, len(input)-1)
+	}
+}
+
+func TestBlockErrorRecovery(t *testing.T) {
+	input := `if (x) { let = 1; x } else { y }; fn(a, ) { a }; let ok = fn() { 1 }`
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+
+	expectedErrors := []string{
+		"1:14: expected next token to be IDENT, but got =",
+		"1:41: expected next token to be IDENT, but got )",
+	}
+	errors := p.Errors()
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%q)", len(expectedErrors), len(errors), errors)
+	}
+	for i, expected := range expectedErrors {
+		if errors[i] != expected {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, expected, errors[i])
+		}
+	}
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+	if !testLetStatement(t, program.Statements[0], "ok") {
+		return
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello \"world\"";`
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", program.Statements[0])
+	}
+
+	if literal.Value != `hello "world"` {
+		t.Errorf("literal.Value not %q. got=%q", `hello "world"`, literal.Value)
+	}
+	if literal.String() != `"hello \"world\""` {
+		t.Errorf("literal.String() wrong. got=%q", literal.String())
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := "let s = \"x=${x}, sum=${a + b * 2}\\n\";"
+
+	I := lexer.Load(input)
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	str, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("value not *ast.InterpolatedString. got=%T", program.Statements[0].(*ast.LetStatement).Value)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. expected=5, got=%d", len(str.Parts))
+	}
+	testIdentifier(t, str.Parts[1], "x")
+	if str.Parts[3].String() != "(a+(b*2))" {
+		t.Errorf("str.Parts[3].String() wrong. got=%q", str.Parts[3].String())
+	}
+	if str.Parts[4].(*ast.StringLiteral).Value != "\n" {
+		t.Errorf("str.Parts[4] wrong. got=%q", str.Parts[4].(*ast.StringLiteral).Value)
+	}
+
+	// 插值表达式中节点的位置指向原始源码
+	if pos := str.Parts[3].Pos().String(); pos != "1:24" {
+		t.Errorf("str.Parts[3].Pos() wrong. expected=%q, got=%q", "1:24", pos)
+	}
+
+	if str.String() != `"x=${x}, sum=${(a+(b*2))}\n"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+}
+
+func TestStringErrorParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let s = \"abc;\nlet t = 1;", "1:9: unterminated string literal"},
+		{`let s = "${}";`, "1:12: empty expression in string interpolation"},
+		{`let s = "${a b}";`, "1:14: expected } to end the interpolation, but got IDENT"},
+		{`let s = "${a +}";`, "1:15: no prefix parse function for EOF found"},
+		{`let s = "\q";`, "1:10: unknown escape sequence \\q"},
+	}
+
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("input %q: expected 1 error, got=%d (%q)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("input %q: wrong error. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	input := "let a = 1 @ 2;\nlet b = \"\xff\";"
+	I := lexer.Load(input)
+	p := Parse(I)
+	p.ParseProgram()
+
+	expected := []string{
+		"1:11: illegal character '@'",
+		"2:10: invalid UTF-8 encoding (byte 0xff)",
+	}
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d (%q)", len(expected), len(errors), errors)
+	}
+	for i, msg := range expected {
+		if errors[i] != msg {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, errors[i])
+		}
+	}
+}
+
+func TestCommentsAttachedToProgram(t *testing.T) {
+	input := `// add two numbers
+let add = fn(a, b) { a + b /* sum */ };`
+
+	I := lexer.Load(input, lexer.WithComments())
+	p := Parse(I)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+
+	if len(program.Comments) != 2 {
+		t.Fatalf("program.Comments should 2 comments. got=%d", len(program.Comments))
+	}
+	if program.Comments[0].Text() != "add two numbers" {
+		t.Errorf("Comments[0].Text() wrong. got=%q", program.Comments[0].Text())
+	}
+	if program.Comments[1].Text() != "sum" || program.Comments[1].Pos().String() != "2:28" {
+		t.Errorf("Comments[1] wrong. got=%q at %s", program.Comments[1].Text(), program.Comments[1].Pos())
+	}
+}
+
+func TestNumberLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"0x1F", int64(31)},
+		{"0o17", int64(15)},
+		{"0b1010", int64(10)},
+		{"1_000_000", int64(1000000)},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500.0},
+		{"1_0.5", 10.5},
+	}
+
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+		switch expected := tt.expected.(type) {
+		case int64:
+			lit, ok := exp.(*ast.IntegerLiteral)
+			if !ok || lit.Value != expected {
+				t.Errorf("input %q: expected IntegerLiteral %d, got=%T (%s)", tt.input, expected, exp, exp)
+			}
+		case float64:
+			lit, ok := exp.(*ast.FloatLiteral)
+			if !ok || lit.Value != expected {
+				t.Errorf("input %q: expected FloatLiteral %g, got=%T (%s)", tt.input, expected, exp, exp)
+			}
+		}
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let a = 9223372036854775808;", "1:9: integer literal 9223372036854775808 overflows int64"},
+		{"let a = 0xFFFFFFFFFFFFFFFFF;", "1:9: integer literal 0xFFFFFFFFFFFFFFFFF overflows int64"},
+		{"let a = 1e400;", "1:9: float literal 1e400 is out of range for float64"},
+		{"let a = 0b12;", "1:9: invalid digit '2' in binary literal"},
+	}
+
+	for _, tt := range tests {
+		I := lexer.Load(tt.input)
+		p := Parse(I)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expectedError {
+			t.Errorf("input %q: wrong errors. expected=%q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	p := Parse(lexer.Load(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	array, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+
+	if array.End().Offset != len(input) {
+		t.Errorf("array.End().Offset = %d, want %d", array.End().Offset, len(input))
+	}
+
+	p = Parse(lexer.Load("[]"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if array := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteral); len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	p := Parse(lexer.Load(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	indexExp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+	if indexExp.Pos().String() != "1:1" || indexExp.End().Offset != len(input) {
+		t.Errorf("wrong span. got=%s..%d", indexExp.Pos(), indexExp.End().Offset)
+	}
+}
+
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLow  interface{}
+		expectedHigh interface{}
+	}{
+		{"a[1:3]", 1, 3},
+		{"a[:3]", nil, 3},
+		{"a[1:]", 1, nil},
+		{"a[:]", nil, nil},
+		{"a[-2:-1]", "(-2)", "(-1)"},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		slice, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+		}
+		testIdentifier(t, slice.Left, "a")
+
+		bounds := []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{slice.Low, tt.expectedLow}, {slice.High, tt.expectedHigh}}
+		for _, b := range bounds {
+			switch expected := b.expected.(type) {
+			case nil:
+				if b.exp != nil {
+					t.Errorf("%s: bound should be omitted. got=%s", tt.input, b.exp)
+				}
+			case int:
+				testIntegerLiteral(t, b.exp, int64(expected))
+			case string:
+				if b.exp == nil || b.exp.String() != expected {
+					t.Errorf("%s: wrong bound. expected=%s, got=%v", tt.input, expected, b.exp)
+				}
+			}
+		}
+		if slice.End().Offset != len(tt.input) {
+			t.Errorf("%s: slice.End().Offset = %d, want %d", tt.input, slice.End().Offset, len(tt.input))
+		}
+	}
+}
+
+func TestIndexExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"a[1", "1:4: expected next token to be ], but got EOF"},
+		{"a[1:2", "1:6: expected next token to be ], but got EOF"},
+		{"[1, 2", "1:6: expected next token to be ], but got EOF"},
+		{"a[]", "1:3: no prefix parse function for ] found"},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedMessage {
+			t.Errorf("%s: wrong errors. expected first=%q, got=%q", tt.input, tt.expectedMessage, errors)
+		}
+	}
+}
+
+func TestHashLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"one": 1, "two": 2, "three": 3}`, `{"one": 1, "two": 2, "three": 3}`},
+		{`{}`, `{}`},
+		{`{"one": 0 + 1, two: 10 - 8, 3: 15 / 5}`, `{"one": (0+1), two: (10-8), 3: (15/5)}`},
+		{`{true: fn(x) { x }, "k": [1, 2],}`, `{true: fn(x) x, "k": [1, 2]}`},
+		{`{"a": {"b": 1}}["a"]["b"]`, `(({"a": {"b": 1}}["a"])["b"])`},
+		{`let h = {1: 2}; h`, `let h = {1: 2};h`},
+		{`fn() { {"a": 1} }`, `fn() {"a": 1}`},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	input := `{"one": 1, 2: "two"}`
+	p := Parse(lexer.Load(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+	if lit, ok := hash.Pairs[0].Key.(*ast.StringLiteral); !ok || lit.Value != "one" {
+		t.Errorf("first key is not \"one\". got=%s", hash.Pairs[0].Key)
+	}
+	testIntegerLiteral(t, hash.Pairs[0].Value, 1)
+	testIntegerLiteral(t, hash.Pairs[1].Key, 2)
+	if hash.End().Offset != len(input) {
+		t.Errorf("hash.End().Offset = %d, want %d", hash.End().Offset, len(input))
+	}
+}
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`{"a" 1}`, "1:6: expected next token to be :, but got INT"},
+		{`{"a": 1 "b": 2}`, "1:9: expected next token to be ,, but got STRING"},
+		{`{"a": 1`, "1:8: expected next token to be ,, but got EOF"},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedMessage {
+			t.Errorf("%s: wrong errors. expected first=%q, got=%q", tt.input, tt.expectedMessage, errors)
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedOperator string
+		expectedTarget   string
+		expectedValue    string
+	}{
+		{"x = 5;", "=", "x", "5"},
+		{"x += 1", "+=", "x", "1"},
+		{"x -= y", "-=", "x", "y"},
+		{"x *= 2 + 3", "*=", "x", "(2+3)"},
+		{"x /= 2", "/=", "x", "2"},
+		{"arr[0] = 5", "=", "(arr[0])", "5"},
+		{`h["k"] = v`, "=", `(h["k"])`, "v"},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		exp, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("%s: exp is not *ast.AssignExpression. got=%T", tt.input, program.Statements[0].(*ast.ExpressionStatement).Expression)
+		}
+		if exp.Operator != tt.expectedOperator {
+			t.Errorf("%s: wrong operator. expected=%q, got=%q", tt.input, tt.expectedOperator, exp.Operator)
+		}
+		if exp.Target.String() != tt.expectedTarget {
+			t.Errorf("%s: wrong target. expected=%q, got=%q", tt.input, tt.expectedTarget, exp.Target.String())
+		}
+		if exp.Value.String() != tt.expectedValue {
+			t.Errorf("%s: wrong value. expected=%q, got=%q", tt.input, tt.expectedValue, exp.Value.String())
+		}
+	}
+}
+
+func TestInvalidAssignTargets(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"1 = 2", "1:1: cannot assign to 1"},
+		{"a + b = 3", "1:1: cannot assign to (a+b)"},
+		{"f() = 1", "1:1: cannot assign to f()"},
+		{"a[1:2] = [1]", "1:1: cannot assign to (a[1:2])"},
+		{"let x = 1; -x += 1; x = 2", "1:12: cannot assign to (-x)"},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		program := p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expectedMessage {
+			t.Errorf("%s: wrong errors. expected=%q, got=%q", tt.input, tt.expectedMessage, errors)
+		}
+		if d := p.Diagnostics(); len(d) == 1 && d[0].Code != diagnostic.InvalidAssign {
+			t.Errorf("%s: wrong code. got=%s", tt.input, d[0].Code)
+		}
+		// 出错的语句被丢弃, 之后的语句照常解析
+		if tt.input == "let x = 1; -x += 1; x = 2" && len(program.Statements) != 2 {
+			t.Errorf("%s: wrong statements. got=%q", tt.input, program.String())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := "while (x < 10) { x += 1; if (x == 5) { break; } continue }"
+
+	p := Parse(lexer.Load(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements should 1 statement. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body should have 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("last statement is not *ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+	if stmt.End().Offset != len(input) {
+		t.Errorf("stmt.End().Offset = %d, want %d", stmt.End().Offset, len(input))
+	}
+	expected := "while ((x<10)) (x+=1)if(x==5) break;continue;"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. expected=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := "for (item in [1, 2, 3]) { puts(item) }"
+
+	p := Parse(lexer.Load(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("stmt.Iterable is not *ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+	if stmt.String() != "for (item in [1, 2, 3]) puts(item)" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestLoopOptionalSemicolon(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"while (i < 3) { i += 1 }; puts(i)", []string{"*ast.WhileStatement", "*ast.ExpressionStatement"}},
+		{"while (i < 3) { i += 1 } puts(i)", []string{"*ast.WhileStatement", "*ast.ExpressionStatement"}},
+		{"for (x in a) { };", []string{"*ast.ForStatement"}},
+		{"for (x in a) { }; let y = 1;", []string{"*ast.ForStatement", "*ast.LetStatement"}},
+		{"for (x in a) { while (x) { break; }; continue; }; x", []string{"*ast.ForStatement", "*ast.ExpressionStatement"}},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var got []string
+		for _, stmt := range program.Statements {
+			got = append(got, fmt.Sprintf("%T", stmt))
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: wrong statements.\nexpected=%v\ngot=     %v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedMessages []string
+	}{
+		{"break", []string{"1:1: break outside of a loop"}},
+		{"if (true) { continue; }", []string{"1:13: continue outside of a loop"}},
+		// 函数体中的break不能作用于外层循环
+		{"while (true) { let f = fn() { break }; }", []string{"1:31: break outside of a loop"}},
+		{"while (true) { fn() { 1 }; break; }", nil},
+		{"for (x in xs) { while (x) { break } continue }", nil},
+		{"for x in xs { }", []string{"1:5: expected next token to be (, but got IDENT"}},
+		{"for (1 in xs) { }", []string{"1:6: expected next token to be IDENT, but got INT"}},
+		{"for (x of xs) { }", []string{"1:8: expected next token to be IN, but got IDENT"}},
+		{"while (x) 1; let y = 2", []string{"1:11: expected next token to be {, but got INT"}},
+		// 出错后从下一条语句继续解析
+		{"break; let = 1; while (1) { continue }", []string{
+			"1:1: break outside of a loop",
+			"1:12: expected next token to be IDENT, but got =",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := Parse(lexer.Load(tt.input))
+		p.ParseProgram()
+		if !reflect.DeepEqual(p.Errors(), tt.expectedMessages) && !(len(tt.expectedMessages) == 0 && len(p.Errors()) == 0) {
+			t.Errorf("%s: wrong errors.\nexpected=%q\ngot=     %q", tt.input, tt.expectedMessages, p.Errors())
+		}
+	}
+}
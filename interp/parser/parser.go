@@ -1,34 +1,63 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 
 	"go_interp/interp/ast"
+	"go_interp/interp/diagnostic"
 	"go_interp/interp/lexer"
 	"go_interp/model/token"
 )
 
+// 优先级从低到高, 与C语言一致
 const (
 	_init = iota
 	LOWEST
-	EQUALS
-	LESSGREATER
-	SUM
-	PRODUCT
-	PREFIX
-	CALL
+	ASSIGN      // = += -= *= /=
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	EQUALS      // == !=
+	LESSGREATER // < > <= >=
+	SHIFT       // << >>
+	SUM         // + -
+	PRODUCT     // * / %
+	PREFIX      // -x !x ~x
+	CALL        // f(x)
+	INDEX       // a[i] a[i:j]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.BIT_OR:          BIT_OR,
+	token.BIT_XOR:         BIT_XOR,
+	token.BIT_AND:         BIT_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.SHL:             SHIFT,
+	token.SHR:             SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
 }
 
 type Parser struct {
@@ -37,7 +66,10 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
-	errors []string
+	diagnostics diagnostic.List
+	comments    []*ast.Comment
+	// loopDepth 当前所在循环的嵌套层数, 用于检查break/continue; 进入函数字面量时重新计数
+	loopDepth int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -59,17 +91,25 @@ func (p *Parser) registerInfixFn(tokenType token.TokenType, fn infixParseFn) {
 func Parse(L *lexer.Lexer) *Parser {
 	p := &Parser{
 		L:              L,
-		errors:         []string{},
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 	}
 	p.nextToken()
 	p.nextToken()
 	p.registerPrefixFn(token.IDENT, p.parseIdentifier)
 	p.registerPrefixFn(token.INT, p.parseIntegerLiteral)
+	p.registerPrefixFn(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefixFn(token.STRING, p.parseStringLiteral)
+	p.registerPrefixFn(token.INTERP_STRING, p.parseInterpolatedString)
 	p.registerPrefixFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixFn(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefixFn(token.BIT_NOT, p.parsePrefixExpression)
 	p.registerPrefixFn(token.TRUE, p.parseBoolean)
 	p.registerPrefixFn(token.FALSE, p.parseBoolean)
+	p.registerPrefixFn(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefixFn(token.IF, p.parseIfExpression)
+	p.registerPrefixFn(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefixFn(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefixFn(token.LBRACE, p.parseHashLiteral)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfixFn(token.PLUS, p.parseInfixExpression)
 	p.registerInfixFn(token.MINUS, p.parseInfixExpression)
@@ -79,12 +119,34 @@ func Parse(L *lexer.Lexer) *Parser {
 	p.registerInfixFn(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfixFn(token.LT, p.parseInfixExpression)
 	p.registerInfixFn(token.GT, p.parseInfixExpression)
+	p.registerInfixFn(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfixFn(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfixFn(token.PERCENT, p.parseInfixExpression)
+	p.registerInfixFn(token.AND, p.parseInfixExpression)
+	p.registerInfixFn(token.OR, p.parseInfixExpression)
+	p.registerInfixFn(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfixFn(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfixFn(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfixFn(token.SHL, p.parseInfixExpression)
+	p.registerInfixFn(token.SHR, p.parseInfixExpression)
+	p.registerInfixFn(token.LPAREN, p.parseCallExpression)
+	p.registerInfixFn(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfixFn(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfixFn(token.SLASH_ASSIGN, p.parseAssignExpression)
 	return p
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.L.NextToken()
+	// 注释不参与语法分析, 收集起来挂到Program上
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.L.NextToken()
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -92,21 +154,70 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
+		errCount := len(p.diagnostics)
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if len(p.diagnostics) > errCount {
+			// 出错的语句整体丢弃, 跳到下一条语句的开头继续解析, 以便一次报告所有互不相关的错误
+			p.synchronize()
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 	return program
 }
 
+// synchronize 出错后丢弃词法单元, 直到当前词法单元为';', 或下一个词法单元可以开始一条新语句.
+// 返回后调用方再前进一个词法单元即可从新语句开始解析
+// 跳过的词法单元中出现的'{...}'会被整体跳过, 其中的关键字不作为同步点
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+		if depth == 0 {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+			if syncTokens[p.peekToken.Type] {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// syncTokens 可以作为新语句开头的词法单元, 以及结束当前块的'}'
+var syncTokens = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.IF:       true,
+	token.FUNCTION: true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.RBRACE:   true,
+	token.EOF:      true,
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -117,7 +228,7 @@ func (p *Parser) parseLetStatement() ast.Statement {
 		Token: p.curToken,
 	}
 
-	if !p.expectPeek(token.IDENT) {
+	if !p.expectPeekHint(token.IDENT, letNameHint) {
 		return nil
 	}
 
@@ -126,12 +237,15 @@ func (p *Parser) parseLetStatement() ast.Statement {
 		Value: p.curToken.Literal,
 	}
 
-	if !p.expectPeek(token.ASSIGN) {
+	if !p.expectPeekHint(token.ASSIGN, letAssignHint) {
 		return nil
 	}
 
-	for !p.curTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken() // 分号可选
 	}
 
 	return stmt
@@ -142,41 +256,175 @@ func (p *Parser) curTokenIs(tokenType token.TokenType) bool {
 }
 
 func (p *Parser) expectPeek(tokenType token.TokenType) bool {
+	return p.expectPeekHint(tokenType, "")
+}
+
+// expectPeekHint 与expectPeek相同, 缺少该词法单元时在诊断信息中附上hint
+func (p *Parser) expectPeekHint(tokenType token.TokenType, hint string) bool {
 	if p.peekTokenIs(tokenType) {
 		p.nextToken()
 		return true
-	} else {
-		p.peekError(tokenType)
-		return false
 	}
+	p.peekError(tokenType, hint)
+	return false
 }
 
 func (p *Parser) peekTokenIs(tokenType token.TokenType) bool {
 	return p.peekToken.Type == tokenType
 }
 
+// Errors returns the message of every diagnostic, prefixed with its position.
 func (p *Parser) Errors() []string {
-	return p.errors
+	return p.diagnostics.Messages()
+}
+
+// Diagnostics returns the structured diagnostics reported while parsing.
+func (p *Parser) Diagnostics() diagnostic.List {
+	return p.diagnostics
+}
+
+// Err returns the diagnostics as a single error, or nil if parsing succeeded.
+func (p *Parser) Err() error {
+	return p.diagnostics.Err()
 }
 
-func (p *Parser) peekError(tok token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, but got %s", tok, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+// report 记录一条错误级别的诊断信息
+func (p *Parser) report(code diagnostic.Code, span token.Span, format string, a ...interface{}) *diagnostic.Diagnostic {
+	d := &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+	}
+	// 同一位置只报告第一个错误, 避免一个坏词法单元引起连锁报错
+	if n := len(p.diagnostics); n > 0 && p.diagnostics[n-1].Span.Start.Offset == span.Start.Offset {
+		return d
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	return d
 }
 
+func (p *Parser) peekError(tok token.TokenType, hint string) {
+	if p.peekTokenIs(token.ILLEGAL) {
+		p.illegalTokenError(p.peekToken)
+		return
+	}
+	d := p.report(diagnostic.UnexpectedToken, p.peekToken.Span(),
+		"expected next token to be %s, but got %s", tok, p.peekToken.Type)
+	d.Expected = tok
+	d.Actual = p.peekToken.Type
+	d.Hint = hint
+}
+
+// 缺少名字或等号时给出的修复建议, 按所在的语法结构选择
+const (
+	letNameHint   = "let statements need a name: let <name> = <expression>;"
+	letAssignHint = "let statements have the form let <name> = <expression>;"
+	paramHint     = "function parameters must be names: fn(<name>, <name>) { ... }"
+	loopVarHint   = "for loops bind a name to each element: for (<name> in <expression>) { ... }"
+)
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{
 		Token: p.curToken,
 	}
 	p.nextToken()
 
-	for !p.curTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken() // 分号可选
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken() // 分号可选
+	}
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeekHint(token.IDENT, loopVarHint) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
 	}
 
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken() // 分号可选
+	}
 	return stmt
 }
 
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() {
+		p.loopDepth--
+	}()
+	return p.parseBlockStatement()
+}
+
+// parseBranchStatement 解析break或continue, 二者只能出现在循环体中
+func (p *Parser) parseBranchStatement() ast.Statement {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		d := p.report(diagnostic.BranchOutside, tok.Span(), "%s outside of a loop", tok.Literal)
+		d.Hint = "break and continue can only be used inside while and for loops"
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken() // 分号可选
+	}
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() (ret *ast.ExpressionStatement) {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -194,6 +442,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 
 	leftExp := prefix()
+	if leftExp == nil {
+		return nil
+	}
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -202,6 +453,9 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		}
 		p.nextToken()
 		leftExp = infix(leftExp)
+		if leftExp == nil {
+			return nil
+		}
 	}
 	return leftExp
 }
@@ -215,8 +469,29 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		if errors.Is(err, strconv.ErrRange) {
+			d := p.report(diagnostic.InvalidInteger, p.curToken.Span(), "integer literal %s overflows int64", p.curToken.Literal)
+			d.Hint = fmt.Sprintf("integers must be between %d and %d", math.MinInt64, math.MaxInt64)
+			return nil
+		}
+		p.report(diagnostic.InvalidInteger, p.curToken.Span(), "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			p.report(diagnostic.InvalidFloat, p.curToken.Span(), "float literal %s is out of range for float64", p.curToken.Literal)
+			return nil
+		}
+		p.report(diagnostic.InvalidFloat, p.curToken.Span(), "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 
@@ -224,9 +499,25 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// illegalTokenError 非法词法单元的错误已由词法分析器给出, 直接沿用其诊断信息
+func (p *Parser) illegalTokenError(tok token.Token) {
+	if d := p.L.DiagnosticFor(tok); d != nil {
+		if n := len(p.diagnostics); n == 0 || p.diagnostics[n-1] != d {
+			p.diagnostics = append(p.diagnostics, d)
+		}
+		return
+	}
+	p.report(diagnostic.IllegalCharacter, tok.Span(), "illegal token %q", tok.Literal)
+}
+
 func (p *Parser) noPrefixParseFnError(tokenType token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", tokenType)
-	p.errors = append(p.errors, msg)
+	if tokenType == token.ILLEGAL {
+		p.illegalTokenError(p.curToken)
+		return
+	}
+	d := p.report(diagnostic.NoPrefixParseFn, p.curToken.Span(), "no prefix parse function for %s found", tokenType)
+	d.Actual = tokenType
+	d.Hint = "expected an expression"
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -268,9 +559,319 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return retEx
 }
 
+// parseAssignExpression 赋值是右结合的: a = b = 1等价于a = (b = 1)
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(ASSIGN - 1)
+	if exp.Value == nil {
+		return nil
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+		return exp
+	}
+	d := p.report(diagnostic.InvalidAssign, token.Span{Start: target.Pos(), End: target.End()},
+		"cannot assign to %s", target.String())
+	d.Hint = "only variables and index expressions such as a[0] can be assigned to"
+	return nil
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
 		Token: p.curToken,
 		Value: p.curTokenIs(token.TRUE),
 	}
 }
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Consequence = p.parseBlockStatement()
+	if expression.Consequence == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Alternative = p.parseBlockStatement()
+		if expression.Alternative == nil {
+			return nil
+		}
+	}
+	return expression
+}
+
+// parseBlockStatement 解析'{'与'}'之间的语句, 调用时curToken为'{', 返回时curToken为'}'.
+// 块内出错的语句会被跳过, 只有缺少'}'时才返回nil
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmtErrCount := len(p.diagnostics)
+		stmt := p.parseStatement()
+		if len(p.diagnostics) > stmtErrCount {
+			p.synchronize()
+		} else if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+		d := p.report(diagnostic.UnexpectedToken, p.curToken.Span(),
+			"expected %s to close the block opened at %s, but got %s", token.RBRACE, block.Token.Pos, p.curToken.Type)
+		d.Expected = token.RBRACE
+		d.Actual = p.curToken.Type
+		return nil
+	}
+	block.Rbrace = p.curToken
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	params, ok := p.parseFunctionParameters()
+	if !ok {
+		return nil
+	}
+	lit.Parameters = params
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// 函数体中的break/continue不能作用于函数外的循环
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+	if lit.Body == nil {
+		return nil
+	}
+	return lit
+}
+
+// parseFunctionParameters 解析形参列表, 调用时curToken为'(', 返回时curToken为')'
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, bool) {
+	identifiers := []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers, true
+	}
+
+	if !p.expectPeekHint(token.IDENT, paramHint) {
+		return nil, false
+	}
+	identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeekHint(token.IDENT, paramHint) {
+			return nil, false
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, false
+	}
+	return identifiers, true
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+
+	args, ok := p.parseExpressionList(token.RPAREN)
+	if !ok {
+		return nil
+	}
+	exp.Arguments = args
+	exp.Rparen = p.curToken
+	return exp
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	elements, ok := p.parseExpressionList(token.RBRACKET)
+	if !ok {
+		return nil
+	}
+	array.Elements = elements
+	array.Rbracket = p.curToken
+	return array
+}
+
+// parseHashLiteral 块语句只出现在if和fn之后, 由相应的解析函数直接解析,
+// 因此出现在表达式开头的'{'总是哈希表字面量. 允许最后一对之后有逗号
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken
+	return hash
+}
+
+// parseIndexExpression 解析left[index]或切片left[low:high], 切片的两端都可以省略
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	lbracket := p.curToken
+
+	var low ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		low = p.parseExpression(LOWEST)
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: lbracket, Left: left, Index: low, Rbracket: p.curToken}
+		}
+	}
+
+	p.nextToken() // ':'
+	slice := &ast.SliceExpression{Token: lbracket, Left: left, Low: low}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.Rbracket = p.curToken
+	return slice
+}
+
+// parseExpressionList 解析以逗号分隔的表达式, 直到end为止; 返回时curToken为end
+func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, bool) {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list, true
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil, false
+	}
+	return list, true
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseInterpolatedString 将字符串拆分为文本和${...}, 后者用一个从相应位置开始的子解析器解析
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	parts, err := lexer.SplitString(p.curToken.Literal)
+	if err != nil {
+		p.report(diagnostic.UnterminatedString, p.curToken.Span(), "%s", err)
+		return nil
+	}
+
+	for _, part := range parts {
+		if !part.IsExpr {
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: part.Value})
+			continue
+		}
+
+		pos := lexer.Advance(p.curToken.Pos, p.curToken.Literal[:part.Offset])
+		exp := p.parseEmbeddedExpression(part.Value, pos)
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+	}
+	return str
+}
+
+// parseEmbeddedExpression 解析嵌入在字符串中、从pos开始的一个完整表达式
+func (p *Parser) parseEmbeddedExpression(src string, pos token.Pos) ast.Expression {
+	sub := Parse(lexer.LoadAt(src, pos))
+	if sub.curTokenIs(token.EOF) {
+		end := pos
+		end.Offset++
+		p.report(diagnostic.NoPrefixParseFn, token.Span{Start: pos, End: end}, "empty expression in string interpolation")
+		return nil
+	}
+
+	exp := sub.parseExpression(LOWEST)
+	if exp != nil && !sub.peekTokenIs(token.EOF) {
+		sub.report(diagnostic.UnexpectedToken, sub.peekToken.Span(),
+			"expected } to end the interpolation, but got %s", sub.peekToken.Type)
+	}
+	if len(sub.diagnostics) > 0 {
+		p.diagnostics = append(p.diagnostics, sub.diagnostics...)
+		return nil
+	}
+	return exp
+}
@@ -0,0 +1,246 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go_interp/interp/object"
+)
+
+// ErrQuit 由命令返回, 通知REPL结束会话
+var ErrQuit = errors.New("quit")
+
+// Command 以':'开头的REPL命令
+type Command struct {
+	Name string
+	// Usage 参数说明, 如"<file>", 没有参数时为空
+	Usage string
+	Help  string
+	// Run 执行命令, args为命令名之后去掉首尾空白的部分
+	Run func(s *Session, args string) error
+}
+
+// Registry 命令表, 按名字分发命令; 嵌入REPL的程序可以注册自己的命令
+type Registry struct {
+	commands map[string]*Command
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]*Command)}
+}
+
+// Register adds cmd, replacing any command with the same name.
+func (r *Registry) Register(cmd *Command) {
+	r.commands[cmd.Name] = cmd
+}
+
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// Commands returns the registered commands sorted by name.
+func (r *Registry) Commands() []*Command {
+	cmds := make([]*Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// DefaultCommands 包含全部内置命令, Start使用该命令表
+var DefaultCommands = NewDefaultRegistry()
+
+// NewDefaultRegistry returns a new registry holding the built-in commands.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for _, cmd := range builtinCommands() {
+		r.Register(cmd)
+	}
+	return r
+}
+
+// runCommand 解析":name args"并执行对应的命令
+func (s *Session) runCommand(line string) error {
+	name, args := line[1:], ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, args = name[:i], strings.TrimSpace(name[i:])
+	}
+
+	cmd, ok := s.Commands.Lookup(name)
+	if !ok {
+		fmt.Fprintf(s.Out, "unknown command :%s, type :help for a list of commands\n", name)
+		return nil
+	}
+
+	err := cmd.Run(s, args)
+	if err != nil && err != ErrQuit {
+		fmt.Fprintf(s.Out, "%s: %s\n", line[:len(name)+1], err)
+	}
+	return err
+}
+
+func builtinCommands() []*Command {
+	modeCommand := func(mode Mode, help string) *Command {
+		return &Command{
+			Name: string(mode),
+			Help: help,
+			Run: func(s *Session, args string) error {
+				s.Mode = mode
+				fmt.Fprintf(s.Out, "mode: %s\n", mode)
+				return nil
+			},
+		}
+	}
+
+	return []*Command{
+		modeCommand(ModeEval, "evaluate input (default)"),
+		modeCommand(ModeAST, "print the syntax tree of input"),
+		modeCommand(ModeTokens, "print the tokens of input"),
+		{
+			Name: "help",
+			Help: "list the available commands",
+			Run:  helpCommand,
+		},
+		{
+			Name: "env",
+			Help: "list the bindings of the session",
+			Run:  envCommand,
+		},
+		{
+			Name: "reset",
+			Help: "discard every binding",
+			Run: func(s *Session, args string) error {
+				s.Reset()
+				fmt.Fprintln(s.Out, "environment reset")
+				return nil
+			},
+		},
+		{
+			Name:  "load",
+			Usage: "<file>",
+			Help:  "evaluate a source file in the session",
+			Run:   loadCommand,
+		},
+		{
+			Name:  "save",
+			Usage: "<file>",
+			Help:  "write the evaluated inputs of the session to a file",
+			Run:   saveCommand,
+		},
+		{
+			Name:  "type",
+			Usage: "<expr>",
+			Help:  "print the type of an expression",
+			Run:   typeCommand,
+		},
+		{
+			Name:  "time",
+			Usage: "<expr>",
+			Help:  "evaluate an expression and print the elapsed time",
+			Run:   timeCommand,
+		},
+		{
+			Name: "quit",
+			Help: "leave the REPL",
+			Run: func(s *Session, args string) error {
+				return ErrQuit
+			},
+		},
+	}
+}
+
+func helpCommand(s *Session, args string) error {
+	for _, cmd := range s.Commands.Commands() {
+		name := ":" + cmd.Name
+		if cmd.Usage != "" {
+			name += " " + cmd.Usage
+		}
+		fmt.Fprintf(s.Out, "  %-16s %s\n", name, cmd.Help)
+	}
+	return nil
+}
+
+func envCommand(s *Session, args string) error {
+	names := s.Env.Names()
+	if len(names) == 0 {
+		fmt.Fprintln(s.Out, "(no bindings)")
+		return nil
+	}
+	for _, name := range names {
+		val, _ := s.Env.Get(name)
+		fmt.Fprintf(s.Out, "%s = %s\n", name, val.Inspect())
+	}
+	return nil
+}
+
+func loadCommand(s *Session, args string) error {
+	if args == "" {
+		return errors.New("missing file name")
+	}
+	data, err := os.ReadFile(args)
+	if err != nil {
+		return err
+	}
+
+	s.print(s.Eval(args, string(data)))
+	return nil
+}
+
+func saveCommand(s *Session, args string) error {
+	if args == "" {
+		return errors.New("missing file name")
+	}
+
+	var out strings.Builder
+	for _, input := range s.inputs {
+		out.WriteString(input)
+		// 每条输入都以分号结束, 否则下一条以(或[开头时会被解析为调用或下标;
+		// 最后一行有行注释时分号写在下一行, 以免成为注释的一部分
+		if !strings.HasSuffix(input, ";") {
+			if strings.Contains(input[strings.LastIndex(input, "\n")+1:], "//") {
+				out.WriteString("\n")
+			}
+			out.WriteString(";")
+		}
+		out.WriteString("\n")
+	}
+	if err := os.WriteFile(args, []byte(out.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.Out, "saved %d inputs to %s\n", len(s.inputs), args)
+	return nil
+}
+
+func typeCommand(s *Session, args string) error {
+	if args == "" {
+		return errors.New("missing expression")
+	}
+	switch result := s.evalArgument(args).(type) {
+	case nil:
+	case *object.Error:
+		s.print(result)
+	default:
+		fmt.Fprintln(s.Out, result.Type())
+	}
+	return nil
+}
+
+func timeCommand(s *Session, args string) error {
+	if args == "" {
+		return errors.New("missing expression")
+	}
+	start := time.Now()
+	result := s.evalArgument(args)
+	elapsed := time.Since(start)
+	s.print(result)
+	fmt.Fprintf(s.Out, "time: %s\n", elapsed)
+	return nil
+}
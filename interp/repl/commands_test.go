@@ -0,0 +1,198 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_interp/interp/builtins"
+	"go_interp/interp/object"
+)
+
+func TestHelpCommand(t *testing.T) {
+	got := runSession(":help\n")
+	for _, cmd := range DefaultCommands.Commands() {
+		if !strings.Contains(got, ":"+cmd.Name) || !strings.Contains(got, cmd.Help) {
+			t.Errorf("help output does not mention :%s. got=%q", cmd.Name, got)
+		}
+	}
+	if !strings.Contains(got, ":load <file>") {
+		t.Errorf("help output does not show usage. got=%q", got)
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+	input := ":env\nlet b = \"x\"; let a = 1 + 1;\n:env\n"
+	expected := "(no bindings)\n" +
+		"a = 2\n" +
+		"b = x\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestResetCommand(t *testing.T) {
+	input := "let a = 1\n:reset\na\n:env\n"
+	expected := "environment reset\n" +
+		"1:1: error[R003]: identifier not found: a\n" +
+		" 1 | a\n" +
+		"   | ^\n" +
+		"(no bindings)\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestResetKeepsBuiltins(t *testing.T) {
+	var out bytes.Buffer
+	registry := builtins.NewDefaultRegistry(&out)
+	s := NewSession(&out, DefaultCommands)
+	s.Env = object.NewEnvironmentWithBuiltins(registry)
+
+	s.Reset()
+	if s.Env.Builtins() != registry {
+		t.Errorf("reset dropped the builtins of the session")
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.mk")
+	bad := filepath.Join(dir, "bad.mk")
+	if err := os.WriteFile(good, []byte("let a = 2;\nlet b = a * 3;\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("let = 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := fmt.Sprintf(":load %s\nb + a\n:load %s\n:load\n:load %s\n", good, bad, filepath.Join(dir, "missing.mk"))
+	got := runSession(input)
+
+	expectedPrefix := "6\n8\n" + bad + ":1:5: error[P001]: expected next token to be IDENT, but got =\n"
+	if !strings.HasPrefix(got, expectedPrefix) {
+		t.Fatalf("wrong output.\nexpected prefix=%q\ngot=            %q", expectedPrefix, got)
+	}
+	if !strings.Contains(got, ":load: missing file name\n") {
+		t.Errorf("missing file name not reported. got=%q", got)
+	}
+	if !strings.Contains(got, "missing.mk: no such file or directory\n") {
+		t.Errorf("missing file not reported. got=%q", got)
+	}
+}
+
+func TestSaveCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.mk")
+	input := "let a = 1\nfoo\nlet = 2\nlet b = a + 1;\n:save " + file + "\n"
+	expected := "1:1: error[R003]: identifier not found: foo\n"
+
+	got := runSession(input)
+	if !strings.HasPrefix(got, expected) || !strings.HasSuffix(got, "saved 2 inputs to "+file+"\n") {
+		t.Fatalf("wrong output. got=%q", got)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "let a = 1;\nlet b = a + 1;\n" {
+		t.Errorf("wrong file contents. got=%q", data)
+	}
+
+	// 保存的会话可以重新载入
+	if got := runSession(":load " + file + "\nb\n"); got != "2\n" {
+		t.Errorf("saved session does not load. got=%q", got)
+	}
+
+	// 以}或行注释结束的输入之后, 下一条输入不会被接在它后面解析
+	input = "let h = {\"a\": 1}\n[1, 2][0]\nlet f = fn() { h[\"a\"] }\nlet x = f() // one\n[x, 2][1]\n:save " + file + "\n"
+	if got := runSession(input); !strings.HasSuffix(got, "saved 5 inputs to "+file+"\n") {
+		t.Fatalf("wrong output. got=%q", got)
+	}
+	if got := runSession(":load " + file + "\nx\n"); got != "2\n1\n" {
+		t.Errorf("saved session does not load. got=%q", got)
+	}
+}
+
+func TestSaveSkipsCommandArguments(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.mk")
+	input := "let a = 1\n:type a\n:time a + 1\n:save " + file + "\n"
+
+	got := runSession(input)
+	if !strings.HasSuffix(got, "saved 1 inputs to "+file+"\n") {
+		t.Fatalf("wrong output. got=%q", got)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "let a = 1;\n" {
+		t.Errorf("wrong file contents. got=%q", data)
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	input := ":type 1 + 2\n:type 1.5\n:type \"s\"\n:type 1 < 2\n:type\n:type x\n"
+	expected := "INTEGER\n" +
+		"FLOAT\n" +
+		"STRING\n" +
+		"BOOLEAN\n" +
+		":type: missing expression\n" +
+		"1:1: error[R003]: identifier not found: x\n" +
+		" 1 | x\n" +
+		"   | ^\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestTimeCommand(t *testing.T) {
+	got := runSession("let a = 20\n:time a * 2\n")
+	if !strings.HasPrefix(got, "40\ntime: ") {
+		t.Errorf("wrong output. got=%q", got)
+	}
+}
+
+func TestQuitCommand(t *testing.T) {
+	expected := "1\n"
+	if got := runSession("1\n:quit\n2\n"); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestCustomCommands(t *testing.T) {
+	reg := NewDefaultRegistry()
+	reg.Register(&Command{
+		Name: "double",
+		Help: "double an integer binding",
+		Run: func(s *Session, args string) error {
+			result := s.Eval("", args+" * 2")
+			fmt.Fprintln(s.Out, result.Inspect())
+			return nil
+		},
+	})
+	reg.Register(&Command{
+		Name: "fail",
+		Run: func(s *Session, args string) error {
+			return fmt.Errorf("failed with %q", args)
+		},
+	})
+
+	var out bytes.Buffer
+	StartWithCommands(strings.NewReader("let n = 21\n:double n\n:fail a b\n"), &out, reg)
+	got := strings.ReplaceAll(out.String(), PROMPT, "")
+	expected := "42\n:fail: failed with \"a b\"\n"
+	if got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+
+	if _, ok := DefaultCommands.Lookup("double"); ok {
+		t.Errorf("custom command leaked into DefaultCommands")
+	}
+}
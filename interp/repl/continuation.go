@@ -0,0 +1,70 @@
+package repl
+
+import (
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/lexer"
+	"go_interp/model/token"
+)
+
+// continuationTokens 出现在输入末尾时说明表达式还没有写完
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:          true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+	token.PLUS:            true,
+	token.MINUS:           true,
+	token.BANG:            true,
+	token.ASTERISK:        true,
+	token.SLASH:           true,
+	token.PERCENT:         true,
+	token.EQ:              true,
+	token.NOT_EQ:          true,
+	token.LT:              true,
+	token.GT:              true,
+	token.LT_EQ:           true,
+	token.GT_EQ:           true,
+	token.AND:             true,
+	token.OR:              true,
+	token.BIT_AND:         true,
+	token.BIT_OR:          true,
+	token.BIT_XOR:         true,
+	token.BIT_NOT:         true,
+	token.SHL:             true,
+	token.SHR:             true,
+	token.COMMA:           true,
+	token.COLON:           true,
+	token.LET:             true,
+	token.RETURN:          true,
+	token.IF:              true,
+	token.ELSE:            true,
+	token.FUNCTION:        true,
+	token.WHILE:           true,
+	token.FOR:             true,
+	token.IN:              true,
+}
+
+// incomplete 判断输入是否需要继续读取下一行: 括号未闭合、字符串或块注释未结束、或以运算符结尾
+func incomplete(input string) bool {
+	l := lexer.Load(input)
+	depth := 0
+	var last token.Token
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	for _, d := range l.Diagnostics() {
+		if d.Code == diagnostic.UnterminatedString || d.Code == diagnostic.UnterminatedComment {
+			return true
+		}
+	}
+	return depth > 0 || continuationTokens[last.Type]
+}
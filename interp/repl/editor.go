@@ -0,0 +1,225 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+)
+
+// errInterrupt 用户按下Ctrl-C, 放弃当前输入
+var errInterrupt = errors.New("interrupt")
+
+// lineReader 按行读取用户输入, prompt由实现负责输出
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// newLineReader 输入为终端时使用支持行编辑和历史记录的编辑器, 否则逐行读取
+func newLineReader(in io.Reader, out io.Writer) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		history := NewHistory(1000)
+		loadHistoryFile(history)
+		return &lineEditor{
+			in:      bufio.NewReader(f),
+			out:     out,
+			history: history,
+			raw: func() (func(), error) {
+				return makeRaw(f.Fd())
+			},
+		}
+	}
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// lineEditor 简单的行编辑器: 左右移动光标、删除、Home/End, 以及上下键浏览历史记录
+type lineEditor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *History
+	// raw 切换终端到raw模式并返回恢复函数; 为nil时不切换(用于测试)
+	raw func() (func(), error)
+}
+
+// 控制字符
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyCtrlK     = 11
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	var line []rune
+	cursor := 0
+	// histPos 当前显示的历史记录下标, 等于history.Len()时表示正在编辑的新行
+	histPos := e.history.Len()
+	var draft []rune
+
+	fmt.Fprint(e.out, prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, keyNewline:
+			fmt.Fprint(e.out, "\r\n")
+			text := string(line)
+			e.history.Add(text)
+			return text, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			line, cursor = deleteAt(line, cursor)
+		case keyBackspace, keyDelete:
+			if cursor > 0 {
+				line, cursor = deleteAt(line, cursor-1)
+			}
+		case keyCtrlA:
+			cursor = 0
+		case keyCtrlE:
+			cursor = len(line)
+		case keyCtrlB:
+			if cursor > 0 {
+				cursor--
+			}
+		case keyCtrlF:
+			if cursor < len(line) {
+				cursor++
+			}
+		case keyCtrlK:
+			line = line[:cursor]
+		case keyCtrlU:
+			line = append([]rune{}, line[cursor:]...)
+			cursor = 0
+		case keyCtrlP:
+			line, cursor, histPos, draft = e.recall(line, cursor, histPos-1, histPos, draft)
+		case keyCtrlN:
+			line, cursor, histPos, draft = e.recall(line, cursor, histPos+1, histPos, draft)
+		case keyEscape:
+			switch e.readEscape() {
+			case "[A":
+				line, cursor, histPos, draft = e.recall(line, cursor, histPos-1, histPos, draft)
+			case "[B":
+				line, cursor, histPos, draft = e.recall(line, cursor, histPos+1, histPos, draft)
+			case "[C":
+				if cursor < len(line) {
+					cursor++
+				}
+			case "[D":
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				cursor = 0
+			case "[F", "OF", "[4~", "[8~":
+				cursor = len(line)
+			case "[3~":
+				if cursor < len(line) {
+					line, cursor = deleteAt(line, cursor)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) || r == '\t' {
+				line = append(line[:cursor], append([]rune{r}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+		e.refresh(prompt, line, cursor)
+	}
+}
+
+// recall 切换到第to条历史记录; 离开正在编辑的新行时将其保存到draft, 回来时恢复
+func (e *lineEditor) recall(line []rune, cursor, to, from int, draft []rune) ([]rune, int, int, []rune) {
+	if to < 0 || to > e.history.Len() {
+		return line, cursor, from, draft
+	}
+	if from == e.history.Len() {
+		draft = line
+	}
+	if to == e.history.Len() {
+		line = draft
+	} else {
+		line = []rune(e.history.At(to))
+	}
+	return line, len(line), to, draft
+}
+
+// readEscape 读取ESC之后的转义序列, 如"[A"或"[3~"
+func (e *lineEditor) readEscape() string {
+	first, _, err := e.in.ReadRune()
+	if err != nil || (first != '[' && first != 'O') {
+		return ""
+	}
+	seq := []rune{first}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		// 转义序列以字母或'~'结束
+		if unicode.IsLetter(r) || r == '~' {
+			return string(seq)
+		}
+	}
+}
+
+// refresh 重绘当前行并把光标移到正确的位置
+func (e *lineEditor) refresh(prompt string, line []rune, cursor int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func deleteAt(line []rune, i int) ([]rune, int) {
+	if i >= len(line) {
+		return line, i
+	}
+	return append(line[:i], line[i+1:]...), i
+}
@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(input string, history *History) *lineEditor {
+	return &lineEditor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     &bytes.Buffer{},
+		history: history,
+	}
+}
+
+func TestLineEditorEditing(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain", "let x = 1\r", "let x = 1"},
+		{"backspace", "lett\x7f x\r", "let x"},
+		{"left and insert", "ac\x1b[Db\r", "abc"},
+		{"home and end", "bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		{"ctrl-a and ctrl-e", "bc\x01a\x05d\r", "abcd"},
+		{"delete key", "abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"kill to end", "abcdef\x1b[D\x1b[D\x1b[D\x0b\r", "abc"},
+		{"kill to start", "abcdef\x1b[D\x1b[D\x15\r", "ef"},
+		{"unicode", "变量\x1b[D\x7f\r", "量"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.input, NewHistory(10))
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("%s: readLine() returned error: %v", tt.name, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%s: readLine() = %q, want %q", tt.name, line, tt.expected)
+		}
+	}
+}
+
+func TestLineEditorHistory(t *testing.T) {
+	history := NewHistory(10)
+	history.Add("first")
+	history.Add("second")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"up", "\x1b[A\r", "second"},
+		{"up twice", "\x1b[A\x1b[A\r", "first"},
+		{"past oldest", "\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"up and down restores draft", "dra\x1b[A\x1b[Bft\r", "draft"},
+		{"edit recalled line", "\x1b[A!\r", "second!"},
+	}
+
+	for _, tt := range tests {
+		h := NewHistory(10)
+		h.Add("first")
+		h.Add("second")
+		e := newTestEditor(tt.input, h)
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("%s: readLine() returned error: %v", tt.name, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%s: readLine() = %q, want %q", tt.name, line, tt.expected)
+		}
+	}
+
+	e := newTestEditor("third\r", history)
+	e.readLine(PROMPT)
+	if history.Len() != 3 || history.At(2) != "third" {
+		t.Errorf("entered line not added to history. got %d entries", history.Len())
+	}
+}
+
+func TestLineEditorControlKeys(t *testing.T) {
+	e := newTestEditor("abc\x03", NewHistory(10))
+	if _, err := e.readLine(PROMPT); err != errInterrupt {
+		t.Errorf("ctrl-c: expected errInterrupt, got %v", err)
+	}
+
+	e = newTestEditor("\x04", NewHistory(10))
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("ctrl-d on empty line: expected io.EOF, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(3)
+	for _, line := range []string{"a", "b", "b", "", "c", "d"} {
+		h.Add(line)
+	}
+
+	var out bytes.Buffer
+	h.Save(&out)
+	if out.String() != "b\nc\nd\n" {
+		t.Errorf("Save() wrong. got=%q", out.String())
+	}
+
+	loaded := NewHistory(2)
+	if err := loaded.Load(strings.NewReader(out.String())); err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if loaded.Len() != 2 || loaded.At(0) != "c" || loaded.At(1) != "d" {
+		t.Errorf("Load() kept wrong entries. got %d entries", loaded.Len())
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, HISTORY_FILE)
+	os.WriteFile(path, []byte("old\n"), 0600)
+
+	h := NewHistory(10)
+	loadHistoryFile(h)
+	h.Add("new")
+
+	if h.Len() != 2 || h.At(0) != "old" {
+		t.Fatalf("history file not loaded. got %d entries", h.Len())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() returned error: %v", err)
+	}
+	if string(data) != "old\nnew\n" {
+		t.Errorf("history file wrong. got=%q", string(data))
+	}
+}
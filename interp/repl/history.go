@@ -0,0 +1,92 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HISTORY_FILE 历史记录文件名, 位于用户主目录下
+const HISTORY_FILE = ".go_interp_history"
+
+// History 输入历史, 最旧的在前
+type History struct {
+	lines []string
+	max   int
+	// file 非空时, 新增的记录会追加写入该文件
+	file string
+}
+
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// Add appends a line, ignoring blank lines and repeats of the last entry.
+func (h *History) Add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(h.lines); n > 0 && h.lines[n-1] == line {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.max {
+		h.lines = h.lines[len(h.lines)-h.max:]
+	}
+
+	if h.file != "" {
+		if f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+			f.WriteString(line + "\n")
+			f.Close()
+		}
+	}
+}
+
+func (h *History) Len() int {
+	return len(h.lines)
+}
+
+// At returns the i-th entry, 0 being the oldest.
+func (h *History) At(i int) string {
+	return h.lines[i]
+}
+
+// Load reads one entry per line from r, keeping at most the newest max entries.
+func (h *History) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	file := h.file
+	h.file = "" // 读取时不再写回文件
+	for scanner.Scan() {
+		h.Add(scanner.Text())
+	}
+	h.file = file
+	return scanner.Err()
+}
+
+// Save writes every entry to w, one per line.
+func (h *History) Save(w io.Writer) error {
+	for _, line := range h.lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadHistoryFile 读取主目录下的历史记录文件, 并在之后的Add中持续追加
+func loadHistoryFile(h *History) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	h.file = filepath.Join(home, HISTORY_FILE)
+
+	f, err := os.Open(h.file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h.Load(f)
+}
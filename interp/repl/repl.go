@@ -1,33 +1,229 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"strings"
 
+	"go_interp/interp/ast"
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/evaluator"
 	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
 	"go_interp/model/token"
 )
 
 const (
-	PROMPT = ">>"
+	PROMPT = ">> "
+	// CONTINUATION_PROMPT 多行输入时, 后续行的提示符
+	CONTINUATION_PROMPT = ".. "
 )
 
+// Mode 决定REPL如何处理输入的代码
+type Mode string
+
+const (
+	// ModeEval 解析并求值(默认)
+	ModeEval Mode = "eval"
+	// ModeAST 只解析, 输出语法树
+	ModeAST Mode = "ast"
+	// ModeTokens 只做词法分析, 输出词法单元
+	ModeTokens Mode = "tokens"
+)
+
+// Session 一次REPL会话的状态, 环境在多次输入之间保留. 命令通过它访问和修改会话
+type Session struct {
+	Out      io.Writer
+	Env      *object.Environment
+	Mode     Mode
+	Commands *Registry
+
+	// inputs 求值成功的输入, 供:save保存
+	inputs []string
+	// sources 解析过的全部输入. 每段输入的偏移接续前一段, 从而在输出运行时错误时
+	// 能找到错误所在的输入, 即使出错的函数是在之前的输入中定义的
+	sources []source
+	offset  int
+}
+
+// source 一段输入及其在会话中的起始偏移
+type source struct {
+	base int
+	text string
+}
+
+// NewSession creates a session in eval mode with an empty environment.
+func NewSession(out io.Writer, commands *Registry) *Session {
+	return &Session{
+		Out:      out,
+		Env:      object.NewEnvironment(),
+		Mode:     ModeEval,
+		Commands: commands,
+	}
+}
+
+// Start runs a REPL with the default commands until in is exhausted or :quit is entered.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+	StartWithCommands(in, out, DefaultCommands)
+}
+
+// StartWithCommands is like Start, but dispatches ':' commands through commands.
+func StartWithCommands(in io.Reader, out io.Writer, commands *Registry) {
+	reader := newLineReader(in, out)
+	s := NewSession(out, commands)
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, err := readInput(reader)
+		if err == errInterrupt {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(input), ":") {
+			if s.runCommand(strings.TrimSpace(input)) == ErrQuit {
+				return
+			}
+			continue
+		}
+		s.Run(input)
+	}
+}
+
+// readInput 读取一段完整的输入: 若输入尚未结束(如括号未闭合), 则以续行提示符继续读取.
+// 在续行中输入空行会直接提交已读取的内容
+func readInput(reader lineReader) (string, error) {
+	line, err := reader.readLine(PROMPT)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(strings.TrimSpace(line), ":") {
+		return line, nil
+	}
+
+	input := line
+	for incomplete(input) {
+		line, err := reader.readLine(CONTINUATION_PROMPT)
+		if err == io.EOF || (err == nil && strings.TrimSpace(line) == "") {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		input += "\n" + line
+	}
+	return input, nil
+}
+
+// Run handles a piece of source code according to the current mode.
+func (s *Session) Run(input string) {
+	switch s.Mode {
+	case ModeTokens:
+		diagnostic.RenderAll(s.Out, input, PrintTokens(s.Out, "", input))
+	case ModeAST:
+		if program := s.parse("", input); program != nil {
+			PrintAST(s.Out, program)
+		}
+	default:
+		s.print(s.Eval("", input))
+	}
+}
+
+// print 输出求值结果, 运行时错误与语法错误一样以诊断信息的形式输出
+func (s *Session) print(result object.Object) {
+	switch result := result.(type) {
+	case nil:
+	case *object.Error:
+		s.render(result.Diagnostic())
+	default:
+		fmt.Fprintln(s.Out, result.Inspect())
+	}
+}
+
+// render 找到诊断信息所在的输入, 将偏移换算为该输入内的偏移后输出
+func (s *Session) render(d *diagnostic.Diagnostic) {
+	offset := d.Span.Start.Offset
+	for i := len(s.sources) - 1; i >= 0; i-- {
+		src := s.sources[i]
+		if offset >= src.base && offset <= src.base+len(src.text) {
+			shifted := *d
+			shifted.Span.Start.Offset -= src.base
+			shifted.Span.End.Offset -= src.base
+			diagnostic.Render(s.Out, src.text, &shifted)
 			return
 		}
+	}
+	diagnostic.Render(s.Out, "", d)
+}
+
+// Eval parses and evaluates input in the session environment. Syntax errors
+// are rendered to Out and reported by returning nil; runtime errors are
+// returned as *object.Error for the caller to print.
+func (s *Session) Eval(filename, input string) object.Object {
+	program := s.parse(filename, input)
+	if program == nil {
+		return nil
+	}
 
-		line := scanner.Text()
-		I := lexer.Load(line)
+	result := evaluator.Eval(program, s.Env)
+	if _, ok := result.(*object.Error); !ok {
+		s.inputs = append(s.inputs, input)
+	}
+	return result
+}
+
+// evalArgument 求值命令的参数, 如:type和:time的表达式; 与Eval不同, 不记录到会话的输入中,
+// :save不会保存它
+func (s *Session) evalArgument(input string) object.Object {
+	program := s.parse("", input)
+	if program == nil {
+		return nil
+	}
+	return evaluator.Eval(program, s.Env)
+}
+
+// Reset discards every binding and the recorded inputs. The builtins of the
+// previous environment are kept.
+func (s *Session) Reset() {
+	s.Env = object.NewEnvironmentWithBuiltins(s.Env.Builtins())
+	s.inputs = nil
+}
 
-		for tok := I.NextToken(); tok.Type != token.EOF; tok = I.NextToken() {
-			fmt.Fprintf(out, "%+v\n", tok)
+// parse 解析失败时输出诊断信息并返回nil
+func (s *Session) parse(filename, input string) *ast.Program {
+	pos := token.Pos{Filename: filename, Offset: s.offset, Line: 1, Column: 1}
+	s.sources = append(s.sources, source{base: s.offset, text: input})
+	s.offset += len(input) + 1
+
+	p := parser.Parse(lexer.LoadAt(input, pos))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		for _, d := range p.Diagnostics() {
+			s.render(d)
 		}
+		return nil
+	}
+	return program
+}
+
+// PrintTokens writes one line per token of input, comments included, and
+// returns the errors found by the lexer.
+func PrintTokens(out io.Writer, filename, input string) diagnostic.List {
+	l := lexer.LoadFile(filename, input, lexer.WithComments())
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(out, "%-6s %-14s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+	return l.Diagnostics()
+}
+
+// PrintAST writes one line per statement, prefixed by the node type.
+func PrintAST(out io.Writer, program *ast.Program) {
+	for _, stmt := range program.Statements {
+		fmt.Fprintf(out, "%-26T %s\n", stmt, stmt.String())
 	}
 }
@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// runSession 通过Start执行输入, 返回去掉提示符后的输出
+func runSession(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	output := strings.ReplaceAll(out.String(), PROMPT, "")
+	return strings.ReplaceAll(output, CONTINUATION_PROMPT, "")
+}
+
+func TestEvalMode(t *testing.T) {
+	input := "let a = 5;\na * 2\nlet b = a + 1; b\n"
+	expected := "10\n6\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestModeSwitches(t *testing.T) {
+	input := ":ast\nlet x = 1 + 2 * 3\n:tokens\nx + 1\n:eval\nlet x = 4; x\n"
+	expected := "mode: ast\n" +
+		"*ast.LetStatement          let x = (1+(2*3));\n" +
+		"mode: tokens\n" +
+		"1:1    IDENT          \"x\"\n" +
+		"1:3    +              \"+\"\n" +
+		"1:5    INT            \"1\"\n" +
+		"mode: eval\n" +
+		"4\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestParserErrorsAreRendered(t *testing.T) {
+	input := "let = 3\n1 + 1\n"
+	expected := "1:5: error[P001]: expected next token to be IDENT, but got =\n" +
+		" 1 | let = 3\n" +
+		"   |     ^\n" +
+		"   = hint: let statements need a name: let <name> = <expression>;\n" +
+		"2\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestRuntimeErrorsKeepEnvironment(t *testing.T) {
+	input := "let a = 1\nfoo\na + 1\n:nope\n"
+	expected := "1:1: error[R003]: identifier not found: foo\n" +
+		" 1 | foo\n" +
+		"   | ^~~\n" +
+		"2\n" +
+		"unknown command :nope, type :help for a list of commands\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestRuntimeErrorsAreRendered(t *testing.T) {
+	// 出错的函数在之前的输入中定义, 诊断信息应指向定义它的那一行
+	input := "let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(x) { add(x, x) }\n1;\ntwice(true)\n"
+	expected := "1\n" +
+		"2:3: error[R002]: unknown operator: BOOLEAN + BOOLEAN\n" +
+		" 2 |   a + b\n" +
+		"   |   ^~~~~\n" +
+		"   = note: in add, called at 1:21\n" +
+		"   = note: in twice, called at 1:1\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let x = if (true) {\n  1\n} else {\n  2\n};\n(x +\n 2) *\n 3\nlet s = \"line1\nline2\"; s\n"
+	expected := "9\nline1\nline2\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestContinuationPrompt(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader("if (true) {\n1\n}\n"), &out)
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + "1\n" + PROMPT
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestBlankLineSubmitsIncompleteInput(t *testing.T) {
+	input := "(1 + 2\n\n5\n"
+	expected := "1:7: error[P001]: expected next token to be ), but got EOF\n" +
+		" 1 | (1 + 2\n" +
+		"   |       ^\n" +
+		"5\n"
+
+	if got := runSession(input); got != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let x =", true},
+		{"fn(x) {", true},
+		{"fn(x) { x }", false},
+		{"add(1,", true},
+		{"[1, 2,", true},
+		{"[1, 2]", false},
+		{"for (x in", true},
+		{"while (x < 3) {", true},
+		{"1 +", true},
+		{"a &&", true},
+		{"if (x) { 1 } else", true},
+		{`"abc`, true},
+		{`"abc"`, false},
+		{"/* comment", true},
+		{"1 + 1 // comment", false},
+		{"}", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) = %t, want %t", tt.input, got, tt.expected)
+		}
+	}
+}
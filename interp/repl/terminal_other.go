@@ -0,0 +1,14 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+// 非Linux平台不支持raw模式, REPL退化为逐行读取
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
@@ -0,0 +1,366 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go_interp/interp/ast"
+	"go_interp/interp/builtins"
+	"go_interp/interp/compiler"
+	"go_interp/interp/evaluator"
+	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
+)
+
+// conformanceTest 一个在求值器和虚拟机上都要运行的程序
+type conformanceTest struct {
+	input string
+	// expected 程序的值的Inspect(); 没有值时为空, 出错时为"ERROR: 错误信息"
+	expected string
+	// output puts和print写出的内容
+	output string
+}
+
+type engine struct {
+	name string
+	run  func(t *testing.T, program *ast.Program, b object.Builtins) object.Object
+}
+
+var engines = []engine{
+	{"eval", func(t *testing.T, program *ast.Program, b object.Builtins) object.Object {
+		return evaluator.Eval(program, object.NewEnvironmentWithBuiltins(b))
+	}},
+	{"vm", func(t *testing.T, program *ast.Program, b object.Builtins) object.Object {
+		bytecode, err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		return NewWithBuiltins(bytecode, b).Run()
+	}},
+}
+
+func TestConformanceExpressions(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: "1 + 2 * 3", expected: "7"},
+		{input: "10 / 3; 10 % 3", expected: "1"},
+		{input: "-5 + 10", expected: "5"},
+		{input: "2.5 * 2", expected: "5.0"},
+		{input: "1 + 1.5", expected: "2.5"},
+		{input: "7 / 2.0", expected: "3.5"},
+		{input: "5 << 2", expected: "20"},
+		{input: "-8 >> 1", expected: "-4"},
+		{input: "(6 & 3) + (6 | 3) + (6 ^ 3)", expected: "14"},
+		{input: "~5", expected: "-6"},
+		{input: "1 < 2", expected: "true"},
+		{input: "2 <= 2", expected: "true"},
+		{input: "3 >= 4", expected: "false"},
+		{input: "1 == 1.0", expected: "true"},
+		{input: `"a" < "b"`, expected: "true"},
+		{input: "true == true", expected: "true"},
+		{input: "true != false", expected: "true"},
+		{input: "!5", expected: "false"},
+		{input: "!!true", expected: "true"},
+		{input: "1 && 2", expected: "true"},
+		{input: "false || 0", expected: "true"},
+		{input: "false && undefined", expected: "false"},
+		{input: "true || undefined", expected: "true"},
+		{input: "true && null_value", expected: "ERROR: identifier not found: null_value"},
+		{input: `"mon" + "key"`, expected: "monkey"},
+		{input: `"x=${1 + 2}, ${[1, "a"]}"`, expected: `x=3, [1, "a"]`},
+		{input: "if (1 > 2) { 10 }", expected: "null"},
+		{input: "if (false) { 1 } else { 2 }", expected: "2"},
+		{input: "if (1) { 1; 2 }", expected: "2"},
+		{input: "fn(x) { x + 1 }", expected: "fn(x) (x+1)"},
+		{input: "len", expected: "builtin function len"},
+		{input: "", expected: ""},
+		{input: "let a = 1;", expected: ""},
+	})
+}
+
+func TestConformanceBindings(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: "let one = 1; let two = one + one; one + two", expected: "3"},
+		{input: "let x = 1; let x = x + 1; x", expected: "2"},
+		{input: "let x = 1; let f = fn() { let x = x + 10; x }; f() + x", expected: "12"},
+		{input: "let x = 1; x = x + 1; x", expected: "2"},
+		{input: "let x = 5; x -= 2; x *= 3; x /= 2; x", expected: "4"},
+		{input: "let x = 0; let y = x = 5; y + x", expected: "10"},
+		{input: "y = 1", expected: "ERROR: cannot assign to undeclared identifier: y"},
+		{input: "len = 1", expected: "ERROR: cannot assign to undeclared identifier: len"},
+		{input: "x += 1", expected: "ERROR: cannot assign to undeclared identifier: x"},
+		{input: "len += 1", expected: "ERROR: cannot assign to undeclared identifier: len"},
+		{input: "let f = fn() { x -= 1 }; f()", expected: "ERROR: cannot assign to undeclared identifier: x"},
+		{input: "let f = fn() { len = 1 }; len([1])", expected: "1"},
+		{input: "let len = fn(x) { 42 }; len([])", expected: "42"},
+		{
+			// 内置函数在运行时查找, 函数中的名字可以被之后的顶层绑定遮蔽
+			input:    "let f = fn() { len }; let len = 5; puts(f())",
+			expected: "null",
+			output:   "5\n",
+		},
+		{input: "let f = fn() { len = 2 }; let len = 1; f(); len", expected: "2"},
+		{input: "foobar", expected: "ERROR: identifier not found: foobar"},
+		{input: "return 5; 10", expected: "5"},
+		{input: "if (true) { return 1 }; 2", expected: "1"},
+	})
+}
+
+func TestConformanceFunctions(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: "let add = fn(a, b) { a + b }; add(1, add(2, 3))", expected: "6"},
+		{input: "let f = fn() { return 1; 2 }; f()", expected: "1"},
+		{input: "let f = fn() { }; f()", expected: "null"},
+		{input: "let f = fn() { let a = 1; }; f()", expected: "null"},
+		{input: "fn(x) { x * 2 }(21)", expected: "42"},
+		{input: "let twice = fn(f, x) { f(f(x)) }; twice(fn(x) { x + 3 }, 1)", expected: "7"},
+		{
+			input:    "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)",
+			expected: "610",
+		},
+		{
+			input:    "let f = fn() { let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5) }; f()",
+			expected: "120",
+		},
+		{
+			input: "let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };" +
+				"let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(10)",
+			expected: "true",
+		},
+		{input: "let adder = fn(a) { fn(b) { a + b } }; adder(2)(3)", expected: "5"},
+		{input: "fn(a) { fn(b) { fn(c) { a + b + c } } }(1)(2)(3)", expected: "6"},
+		{
+			input:    "let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()",
+			expected: "3",
+		},
+		{
+			// 两个闭包共享同一个变量
+			input:    "let make = fn() { let n = 0; [fn() { n += 1 }, fn() { n }] }; let p = make(); p[0](); p[0](); p[1]()",
+			expected: "2",
+		},
+		{
+			// 外层函数在闭包创建之后修改变量, 闭包看到新的值
+			input:    "let f = fn() { let x = 1; let g = fn() { x }; x = 5; g() }; f()",
+			expected: "5",
+		},
+		{input: "let x = 1; let f = fn() { x }; let x = 2; f()", expected: "2"},
+		{input: "let x = 1; let f = fn() { x = 10 }; f(); x", expected: "10"},
+		{input: "let f = fn() { g() }; let g = fn() { 1 }; f()", expected: "1"},
+		{input: "type(fn() {})", expected: "FUNCTION"},
+	})
+}
+
+func TestConformanceCollections(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: "[1, 2 * 2, 3 + 3]", expected: "[1, 4, 6]"},
+		{input: "[1, 2, 3][1]", expected: "2"},
+		{input: "[1, 2, 3][-1]", expected: "3"},
+		{input: "[1, 2, 3][3]", expected: "ERROR: index 3 out of range for ARRAY of length 3"},
+		{input: "[1, 2, 3][1:]", expected: "[2, 3]"},
+		{input: "[1, 2, 3][:-1]", expected: "[1, 2]"},
+		{input: "[1, 2, 3][:]", expected: "[1, 2, 3]"},
+		{input: "[1, 2, 3][2:1]", expected: "ERROR: invalid slice: low index 2 > high index 1"},
+		{input: `"héllo"[1]`, expected: "é"},
+		{input: `"héllo"[1:3]`, expected: "él"},
+		{input: `1[0]`, expected: "ERROR: index operator not supported: INTEGER"},
+		{input: "let a = [1, 2]; a[0] = 5; a[1] += 1; a", expected: "[5, 3]"},
+		{input: "let a = [[1]]; a[0][0] = 2; a", expected: "[[2]]"},
+		{input: "let a = []; a[0] = 1", expected: "ERROR: index 0 out of range for ARRAY of length 0"},
+		{input: "len([1, 2]) + len(\"abc\")", expected: "5"},
+		{input: "push(rest([1, 2, 3]), first([4]))", expected: "[2, 3, 4]"},
+		{input: `{"a": 1, 2: true}`, expected: `{"a": 1, 2: true}`},
+		{input: `{"a": 1}["a"]`, expected: "1"},
+		{input: `{"a": 1}["b"]`, expected: "null"},
+		{input: `let h = {}; h["b"] = 2; h["b"] *= 5; h`, expected: `{"b": 10}`},
+		{input: `{[1]: 2}`, expected: "ERROR: unusable as hash key: ARRAY"},
+		{input: `{"a": 1}[fn() {}]`, expected: "ERROR: unusable as hash key: FUNCTION"},
+		{input: `let k = "x"; {k: 1, "y": k}`, expected: `{"x": 1, "y": "x"}`},
+		{
+			// 下标赋值使容器包含自身, 输出时内层显示为[...]或{...}
+			input:    "let a = [1]; a[0] = a; puts(a)",
+			expected: "null",
+			output:   "[[...]]\n",
+		},
+		{input: `let h = {}; h["self"] = h; "${h}"`, expected: `{"self": {...}}`},
+	})
+}
+
+func TestConformanceLoops(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: "let i = 0; let sum = 0; while (i < 5) { sum += i; i += 1 } sum", expected: "10"},
+		{input: "while (false) { 1 }", expected: ""},
+		{
+			input:    "let i = 0; while (true) { i += 1; if (i == 3) { break } } i",
+			expected: "3",
+		},
+		{
+			input:    "let s = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue } s += x } s",
+			expected: "4",
+		},
+		{input: `let out = ""; for (c in "abc") { out = c + out } out`, expected: "cba"},
+		{input: `let ks = []; for (k in {"b": 1, "a": 2}) { ks = push(ks, k) } ks`, expected: `["b", "a"]`},
+		{
+			input:    "let n = 0; for (i in range(3)) { for (j in range(3)) { if (j == 1) { break } n += 1 } } n",
+			expected: "3",
+		},
+		{
+			// 遍历开始时的快照
+			input:    "let a = [1, 2, 3]; let n = 0; for (x in a) { a = push(a, x); n += 1 } n",
+			expected: "3",
+		},
+		{input: "for (x in [1]) { } x", expected: "ERROR: identifier not found: x"},
+		{input: "for (x in 5) { }", expected: "ERROR: cannot iterate over INTEGER"},
+		{
+			input:    "let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x } } 0 }; f()",
+			expected: "2",
+		},
+		{
+			input:    "let f = fn() { let i = 0; while (true) { i += 1; if (i > 4) { return i } } }; f()",
+			expected: "5",
+		},
+		{
+			// 每次循环的闭包捕获各自的循环变量
+			input:    "let fs = []; for (i in range(3)) { fs = push(fs, fn() { i * 10 }) } fs[0]() + fs[2]()",
+			expected: "20",
+		},
+		{
+			input: "let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 } " +
+				"fs[0]() + fs[1]() * 10 + fs[2]() * 100",
+			expected: "210",
+		},
+		{
+			input: "let make = fn() { let fs = []; for (i in range(3)) { if (i == 2) { break }; " +
+				"fs = push(fs, fn() { i }) } fs } let fs = make(); fs[0]() + fs[1]() * 10",
+			expected: "10",
+		},
+		{
+			// 循环体中的let遮蔽外层的同名变量, 只在当次循环中可见
+			input:    "let x = 1; let i = 0; while (i < 2) { let x = i * 10; i += 1 } x",
+			expected: "1",
+		},
+		{
+			input:    "let x = 1; for (i in range(2)) { x = x + i } x",
+			expected: "2",
+		},
+		{
+			// 表达式中的break和continue跳出前丢弃已经求值的操作数
+			input:    "let r = []; for (x in [1, 2]) { r = push(r, if (x == 1) { continue } else { x }) } r",
+			expected: "[2]",
+		},
+		{
+			input:    "let i = 0; while (i < 3) { i += 1; puts(1 + if (i == 2) { continue } else { i }) }",
+			expected: "",
+			output:   "2\n4\n",
+		},
+		{
+			input:    "let n = 0; for (i in range(3)) { for (j in [1, 2]) { n += [j, {\"k\": if (i == 1) { break } else { j }}][1][\"k\"] } } n",
+			expected: "6",
+		},
+		{
+			input:    "let f = fn() { for (x in [1]) { 1 + if (true) { return x * 10 } } }; f() + 1",
+			expected: "11",
+		},
+	})
+}
+
+func TestConformanceBuiltins(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: `puts("hello", 1)`, expected: "null", output: "hello\n1\n"},
+		{input: `print("a", [1, "b"])`, expected: "null", output: `a [1, "b"]`},
+		{input: `let f = fn(x) { puts(x); x }; f(1) + f(2)`, expected: "3", output: "1\n2\n"},
+		{input: "type(1) + type(1.5) + type(\"\")", expected: "INTEGERFLOATSTRING"},
+		{input: `str(12) + str(true)`, expected: "12true"},
+		{input: `int("42") + int(1.9)`, expected: "43"},
+		{input: "range(1, 4)", expected: "[1, 2, 3]"},
+		{input: "len(1)", expected: "ERROR: argument 1 to len must be STRING, ARRAY or HASH, got INTEGER"},
+		{input: "len(1, 2)", expected: "ERROR: wrong number of arguments to len: want=1, got=2"},
+	})
+}
+
+func TestConformanceErrors(t *testing.T) {
+	runConformanceTests(t, []conformanceTest{
+		{input: "5 + true;", expected: "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{input: "5 + true; 5;", expected: "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{input: "-true", expected: "ERROR: unknown operator: -BOOLEAN"},
+		{input: "~1.5", expected: "ERROR: unknown operator: ~FLOAT"},
+		{input: "true + false", expected: "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+		{input: `"a" - "b"`, expected: "ERROR: unknown operator: STRING - STRING"},
+		{input: "if (10 > 1) { true + false; 1 }", expected: "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+		{input: "1 / 0", expected: "ERROR: division by zero: 1 / 0"},
+		{input: "1 << -1", expected: "ERROR: negative shift count: 1 << -1"},
+		{input: "1()", expected: "ERROR: not a function: INTEGER"},
+		{input: "fn(x) { x }()", expected: "ERROR: wrong number of arguments: want=1, got=0"},
+		{input: "let x = 1;\nlet f = fn() {\n  x + \"a\"\n};\nf()", expected: "ERROR: type mismatch: INTEGER + STRING"},
+		{
+			input:    "let inner = fn(x) { x / 0 };\nlet outer = fn() { inner(1) };\nouter()",
+			expected: "ERROR: division by zero: 1 / 0",
+		},
+		{input: "let f = fn() { fn() { y }() }; f()", expected: "ERROR: identifier not found: y"},
+		{
+			input:    `let a = [1]; a["x"] += 1`,
+			expected: "ERROR: index must be INTEGER, got STRING",
+		},
+		{input: "let a = [1]; a[0] += true", expected: "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{input: "let i = 0; while (i < 3) { i += 1; if (i == 2) { i + true } }", expected: "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{input: "let f = fn(n) { f(n + 1) };\nf(0)", expected: "ERROR: stack overflow"},
+		{input: "let f = fn() { fn() { f() }() }; f()", expected: "ERROR: stack overflow"},
+		// 两种方式的调用深度限制相同: 恰好MaxCallDepth层可以完成, 再多一层即为栈溢出
+		{
+			input:    fmt.Sprintf("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(%d)", evaluator.MaxCallDepth-1),
+			expected: fmt.Sprint(evaluator.MaxCallDepth - 1),
+		},
+		{
+			input:    fmt.Sprintf("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(%d)", evaluator.MaxCallDepth),
+			expected: "ERROR: stack overflow",
+		},
+	})
+}
+
+// runConformanceTests 每个程序分别由求值器和虚拟机执行, 两者的结果和输出都要与期望一致;
+// 出错时两者报告的位置和调用栈也必须相同
+func runConformanceTests(t *testing.T, tests []conformanceTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		var errors []*object.Error
+		for _, e := range engines {
+			var out bytes.Buffer
+			registry := builtins.NewDefaultRegistry(&out)
+
+			p := parser.Parse(lexer.Load(tt.input))
+			program := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				t.Fatalf("%q: parser errors: %v", tt.input, p.Errors())
+			}
+
+			result := e.run(t, program, registry)
+			got := ""
+			if result != nil {
+				got = result.Inspect()
+			}
+			if got != tt.expected {
+				t.Errorf("%s: %q\n  got:  %s\n  want: %s", e.name, tt.input, got, tt.expected)
+			}
+			if out.String() != tt.output {
+				t.Errorf("%s: %q: output=%q, want %q", e.name, tt.input, out.String(), tt.output)
+			}
+			if err, ok := result.(*object.Error); ok {
+				errors = append(errors, err)
+			}
+		}
+
+		if len(errors) == len(engines) {
+			want := errors[0]
+			for i, err := range errors[1:] {
+				if err.Span != want.Span {
+					t.Errorf("%s: %q: error span %v, %s reports %v", engines[i+1].name, tt.input, err.Span, engines[0].name, want.Span)
+				}
+				if !reflect.DeepEqual(err.Stack, want.Stack) {
+					t.Errorf("%s: %q: error stack %v, %s reports %v", engines[i+1].name, tt.input, err.Stack, engines[0].name, want.Stack)
+				}
+			}
+		}
+	}
+}
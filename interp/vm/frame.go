@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"go_interp/interp/code"
+	"go_interp/interp/object"
+)
+
+// Frame 一次函数调用
+type Frame struct {
+	cl *object.Closure
+	// ip 下一条要执行的指令
+	ip int
+	// op 正在执行的指令的位置; 对调用者而言即OpCall, 用于定位错误和记录调用栈
+	op int
+	// basePointer 局部变量在栈上的起始位置
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,470 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+
+	"go_interp/interp/builtins"
+	"go_interp/interp/code"
+	"go_interp/interp/compiler"
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/evaluator"
+	"go_interp/interp/object"
+)
+
+const (
+	// StackSize 值栈的大小; 闭包通过指针引用栈上的变量, 因此栈不能扩容.
+	// 平均每层调用可用20多个槽位, 通常的函数在达到MaxFrames之前不会用完
+	StackSize = 1 << 18
+	// MaxFrames 与求值器相同, 最多嵌套MaxCallDepth层调用, 另加顶层程序的一帧
+	MaxFrames = evaluator.MaxCallDepth + 1
+)
+
+var (
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
+)
+
+// infixOperators 二元运算操作码对应的运算符, 运算本身与求值器共用
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShl:          "<<",
+	code.OpShr:          ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus:  "-",
+	code.OpBang:   "!",
+	code.OpBitNot: "~",
+}
+
+// VM 执行编译后的字节码, 结果与求值器一致
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	// builtins 全局变量没有绑定时在其中查找同名的内置函数
+	builtins object.Builtins
+
+	stack []object.Object
+	// sp 指向栈顶之上的第一个空位
+	sp int
+
+	frames []*Frame
+	// openUpvalues 仍指向栈上变量的Upvalue, 同一个变量只有一个Upvalue
+	openUpvalues []openUpvalue
+}
+
+type openUpvalue struct {
+	slot    int
+	upvalue *object.Upvalue
+}
+
+// New 创建执行bytecode的虚拟机, 使用标准的内置函数表builtins.Default
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithBuiltins(bytecode, builtins.Default)
+}
+
+// NewWithBuiltins 与New相同, 但内置函数在b中查找
+func NewWithBuiltins(bytecode *compiler.Bytecode, b object.Builtins) *VM {
+	main := &object.Closure{Fn: bytecode.Main}
+
+	vm := &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, len(bytecode.Globals)),
+		globalNames: bytecode.Globals,
+		builtins:    b,
+		stack:       make([]object.Object, StackSize),
+		frames:      []*Frame{NewFrame(main, 0)},
+	}
+	// 顶层程序的局部变量是顶层循环体中的变量
+	vm.sp = bytecode.Main.NumLocals
+	return vm
+}
+
+// Run 执行程序, 返回最后一条表达式语句的值(没有时为nil)或运行时错误
+func (vm *VM) Run() object.Object {
+	for {
+		frame := vm.currentFrame()
+		ins := frame.Instructions()
+		frame.op = frame.ip
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		var err *object.Error
+		switch op {
+		case code.OpConstant:
+			index := vm.readUint16(frame)
+			err = vm.push(vm.constants[index])
+		case code.OpPop:
+			vm.pop()
+		case code.OpTrue:
+			err = vm.push(TRUE)
+		case code.OpFalse:
+			err = vm.push(FALSE)
+		case code.OpNull:
+			err = vm.push(NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShl, code.OpShr,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpLessEqual,
+			code.OpGreaterThan, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalInfix(infixOperators[op], left, right))
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			err = vm.pushResult(evaluator.EvalPrefix(prefixOperators[op], vm.pop()))
+
+		case code.OpJump:
+			frame.ip = vm.readUint16(frame)
+		case code.OpJumpNotTruthy:
+			target := vm.readUint16(frame)
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = target
+			}
+
+		case code.OpGetGlobal:
+			index := vm.readUint16(frame)
+			val := vm.globals[index]
+			if val == nil {
+				// 与求值器一致, 绑定遮蔽同名的内置函数, 没有绑定时才使用内置函数
+				builtin, ok := vm.builtins.Lookup(vm.globalNames[index])
+				if !ok {
+					err = newError(diagnostic.UnknownIdentifier, "identifier not found: %s", vm.globalNames[index])
+					break
+				}
+				val = builtin
+			}
+			err = vm.push(val)
+		case code.OpSetGlobal:
+			vm.globals[vm.readUint16(frame)] = vm.pop()
+		case code.OpAssignGlobal:
+			index := vm.readUint16(frame)
+			if vm.globals[index] == nil {
+				err = newError(diagnostic.UnknownIdentifier, "cannot assign to undeclared identifier: %s", vm.globalNames[index])
+				break
+			}
+			vm.globals[index] = vm.pop()
+		case code.OpCheckGlobal:
+			index := vm.readUint16(frame)
+			if vm.globals[index] == nil {
+				err = newError(diagnostic.UnknownIdentifier, "cannot assign to undeclared identifier: %s", vm.globalNames[index])
+			}
+		case code.OpGetLocal:
+			val := vm.stack[frame.basePointer+vm.readUint8(frame)]
+			if val == nil {
+				// 循环体中未执行到的let留下的空槽位
+				val = NULL
+			}
+			err = vm.push(val)
+		case code.OpSetLocal:
+			vm.stack[frame.basePointer+vm.readUint8(frame)] = vm.pop()
+		case code.OpGetFree:
+			err = vm.push(frame.cl.Free[vm.readUint8(frame)].Get())
+		case code.OpSetFree:
+			frame.cl.Free[vm.readUint8(frame)].Set(vm.pop())
+
+		case code.OpArray:
+			n := vm.readUint16(frame)
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			err = vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			n := vm.readUint16(frame)
+			err = vm.buildHash(vm.sp-n, vm.sp)
+		case code.OpHashKey:
+			if _, ok := vm.stack[vm.sp-1].(object.Hashable); !ok {
+				err = newError(diagnostic.UnhashableKey, "unusable as hash key: %s", vm.stack[vm.sp-1].Type())
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndex(left, index))
+		case code.OpSlice:
+			flags := vm.readUint8(frame)
+			var low, high object.Object
+			if flags&code.SliceHigh != 0 {
+				high = vm.pop()
+			}
+			if flags&code.SliceLow != 0 {
+				low = vm.pop()
+			}
+			err = vm.pushResult(evaluator.EvalSlice(vm.pop(), low, high))
+		case code.OpSetIndex:
+			err = vm.setIndex(vm.readUint8(frame))
+		case code.OpInterpolate:
+			n := vm.readUint16(frame)
+			var out bytes.Buffer
+			for _, part := range vm.stack[vm.sp-n : vm.sp] {
+				out.WriteString(part.Inspect())
+			}
+			vm.sp -= n
+			err = vm.push(&object.String{Value: out.String()})
+
+		case code.OpCall:
+			err = vm.call(vm.readUint8(frame))
+		case code.OpReturnValue, code.OpReturn:
+			var val object.Object = NULL
+			if op == code.OpReturnValue {
+				val = vm.pop()
+			}
+			returned := vm.popFrame()
+			vm.closeUpvalues(returned.basePointer)
+			if len(vm.frames) == 0 {
+				// 顶层程序结束; 与求值器一致, 没有值时结果为nil
+				if op == code.OpReturn {
+					return nil
+				}
+				return val
+			}
+			vm.sp = returned.basePointer - 1
+			err = vm.push(val)
+		case code.OpClosure:
+			index := vm.readUint16(frame)
+			vm.readUint8(frame)
+			err = vm.push(vm.newClosure(vm.constants[index].(*object.CompiledFunction)))
+		case code.OpCloseUpvalues:
+			vm.closeUpvalues(frame.basePointer + vm.readUint8(frame))
+
+		case code.OpIterInit:
+			items, iterErr := evaluator.IterationItems(vm.pop())
+			if iterErr != nil {
+				err = iterErr
+				break
+			}
+			err = vm.push(&iterator{items: items})
+		case code.OpIterNext:
+			target := vm.readUint16(frame)
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.next >= len(it.items) {
+				frame.ip = target
+				break
+			}
+			it.next++
+			err = vm.push(it.items[it.next-1])
+
+		default:
+			err = newError(diagnostic.UnknownOperator, "unknown opcode %d", op)
+		}
+
+		if err != nil {
+			return vm.fail(err)
+		}
+	}
+}
+
+// call 调用栈上的函数; 函数位于参数之下
+func (vm *VM) call(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		fn := callee.Fn
+		if numArgs != fn.NumParameters {
+			return newError(diagnostic.WrongArgumentCount, "wrong number of arguments: want=%d, got=%d",
+				fn.NumParameters, numArgs)
+		}
+		if len(vm.frames) >= MaxFrames {
+			return newError(diagnostic.StackOverflow, "stack overflow")
+		}
+		basePointer := vm.sp - numArgs
+		if basePointer+fn.NumLocals >= StackSize {
+			return newError(diagnostic.StackOverflow, "stack overflow")
+		}
+		vm.frames = append(vm.frames, NewFrame(callee, basePointer))
+		// 局部变量的槽位可能残留之前调用的值, 清空以免循环体读到
+		for i := basePointer + numArgs; i < basePointer+fn.NumLocals; i++ {
+			vm.stack[i] = nil
+		}
+		vm.sp = basePointer + fn.NumLocals
+		return nil
+
+	case *object.Builtin:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		result := callee.Fn(args...)
+		vm.sp = vm.sp - numArgs - 1
+		if result == nil {
+			result = NULL
+		}
+		return vm.pushResult(result)
+	}
+	return newError(diagnostic.NotCallable, "not a function: %s", callee.Type())
+}
+
+// newClosure 按函数的Captures捕获变量: 当前函数的局部变量通过Upvalue引用, 当前闭包已捕获的变量直接共享
+func (vm *VM) newClosure(fn *object.CompiledFunction) *object.Closure {
+	frame := vm.currentFrame()
+	free := make([]*object.Upvalue, len(fn.Captures))
+	for i, capture := range fn.Captures {
+		if capture.Local {
+			free[i] = vm.captureUpvalue(frame.basePointer + capture.Index)
+		} else {
+			free[i] = frame.cl.Free[capture.Index]
+		}
+	}
+	return &object.Closure{Fn: fn, Free: free}
+}
+
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	for _, open := range vm.openUpvalues {
+		if open.slot == slot {
+			return open.upvalue
+		}
+	}
+	upvalue := object.NewUpvalue(&vm.stack[slot])
+	vm.openUpvalues = append(vm.openUpvalues, openUpvalue{slot: slot, upvalue: upvalue})
+	return upvalue
+}
+
+// closeUpvalues 将不小于slot的槽位上被捕获的变量移出栈
+func (vm *VM) closeUpvalues(slot int) {
+	remaining := vm.openUpvalues[:0]
+	for _, open := range vm.openUpvalues {
+		if open.slot >= slot {
+			open.upvalue.Close()
+		} else {
+			remaining = append(remaining, open)
+		}
+	}
+	vm.openUpvalues = remaining
+}
+
+// buildHash 用栈上[start, end)中依次排列的键值对创建哈希表
+func (vm *VM) buildHash(start, end int) *object.Error {
+	hash := object.NewHash()
+	for i := start; i < end; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return newError(diagnostic.UnhashableKey, "unusable as hash key: %s", vm.stack[i].Type())
+		}
+		hash.Set(key, vm.stack[i+1])
+	}
+	vm.sp = start
+	return vm.push(hash)
+}
+
+// setIndex 下标赋值; operator不为0时是复合赋值, 为二元运算的操作码加1
+func (vm *VM) setIndex(operator int) *object.Error {
+	val := vm.pop()
+	index := vm.pop()
+	left := vm.pop()
+
+	if operator != 0 {
+		current := evaluator.EvalIndex(left, index)
+		if err, ok := current.(*object.Error); ok {
+			return err
+		}
+		val = evaluator.EvalInfix(infixOperators[code.Opcode(operator-1)], current, val)
+		if err, ok := val.(*object.Error); ok {
+			return err
+		}
+	}
+	return vm.pushResult(evaluator.EvalIndexAssignment(left, index, val))
+}
+
+// fail 补上错误的位置和调用栈: 位置为正在执行的指令, 每个调用者的OpCall为一层调用栈
+func (vm *VM) fail(err *object.Error) object.Object {
+	frame := vm.currentFrame()
+	if !err.Span.Start.IsValid() {
+		if span, ok := frame.cl.Fn.Lines.Lookup(frame.op); ok {
+			err.Span = span
+		}
+	}
+
+	// 与求值器相同, 栈溢出时只保留最内层的几层
+	last := 0
+	if err.Code == diagnostic.StackOverflow && len(vm.frames)-1 > evaluator.OverflowStackFrames {
+		last = len(vm.frames) - 1 - evaluator.OverflowStackFrames
+	}
+	for i := len(vm.frames) - 1; i > last; i-- {
+		fn := vm.frames[i].cl.Fn
+		caller := vm.frames[i-1]
+
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		span, _ := caller.cl.Fn.Lines.Lookup(caller.op)
+		err.Stack = append(err.Stack, object.Frame{Function: name, Pos: span.Start})
+	}
+	return err
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[len(vm.frames)-1]
+}
+
+func (vm *VM) popFrame() *Frame {
+	frame := vm.currentFrame()
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	return frame
+}
+
+func (vm *VM) readUint16(frame *Frame) int {
+	val := int(code.ReadUint16(frame.Instructions()[frame.ip:]))
+	frame.ip += 2
+	return val
+}
+
+func (vm *VM) readUint8(frame *Frame) int {
+	val := int(code.ReadUint8(frame.Instructions()[frame.ip:]))
+	frame.ip++
+	return val
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return newError(diagnostic.StackOverflow, "stack overflow")
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+// pushResult 压入求值的结果; 结果为错误时返回该错误
+func (vm *VM) pushResult(result object.Object) *object.Error {
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// iterator for循环期间留在栈上, 依次给出被遍历的值
+type iterator struct {
+	items []object.Object
+	next  int
+}
+
+func (it *iterator) Type() object.ObjectType {
+	return "ITERATOR"
+}
+
+func (it *iterator) Inspect() string {
+	return fmt.Sprintf("iterator(%d/%d)", it.next, len(it.items))
+}
+
+func newError(code diagnostic.Code, format string, a ...interface{}) *object.Error {
+	return &object.Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
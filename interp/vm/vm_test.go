@@ -0,0 +1,121 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"go_interp/interp/builtins"
+	"go_interp/interp/compiler"
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/evaluator"
+	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
+)
+
+func runVM(t *testing.T, input string) object.Object {
+	t.Helper()
+	p := parser.Parse(lexer.Load(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	bytecode, err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return New(bytecode).Run()
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []string{
+		"let f = fn() { f() }; f()",
+		"let f = fn(n) { 1 + f(n + 1) }; f(0)",
+	}
+
+	for _, input := range tests {
+		err, ok := runVM(t, input).(*object.Error)
+		if !ok {
+			t.Fatalf("%q: expected an error", input)
+		}
+		if err.Code != diagnostic.StackOverflow {
+			t.Errorf("%q: wrong code. expected=%s, got=%s (%s)", input, diagnostic.StackOverflow, err.Code, err.Message)
+		}
+		if len(err.Stack) != evaluator.OverflowStackFrames {
+			t.Errorf("%q: wrong stack depth. expected=%d, got=%d", input, evaluator.OverflowStackFrames, len(err.Stack))
+		}
+		for _, frame := range err.Stack {
+			if frame.Function != "f" {
+				t.Errorf("%q: unexpected frame %s", input, frame)
+			}
+		}
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	// 恰好嵌套MaxCallDepth层调用, 局部变量和临时值不会用完值栈
+	n := evaluator.MaxCallDepth - 1
+	input := fmt.Sprintf("let sum = fn(n) { let a = n; let b = [a, a, a]; if (n == 0) { 0 } else { b[0] + sum(n - 1) } }; sum(%d)", n)
+	result, ok := runVM(t, input).(*object.Integer)
+	if !ok || result.Value != int64(n*(n+1)/2) {
+		t.Fatalf("wrong result. got=%v", runVM(t, input))
+	}
+}
+
+func TestStackIsBalanced(t *testing.T) {
+	tests := []string{
+		"1; 2; 3",
+		"let a = [1, 2]; for (x in a) { x; if (x == 1) { continue } break }",
+		"let f = fn(x) { while (true) { let y = x; if (y) { return y } } }; f(1); f(2)",
+		`let h = {}; h["a"] = 1; h["a"] += 1`,
+		"let r = []; for (x in [1, 2]) { r = push(r, if (x == 1) { continue } else { x }) }",
+		"let i = 0; while (i < 3) { i += 1; 1 + if (i == 2) { continue } else { i } }",
+		"let i = 0; while (true) { let a = [i, if (i == 2) { break } else { 0 }]; i += 1 }",
+		"for (x in [1]) { for (y in [1]) { [x, \"${y}${if (true) { break }}\"] } }",
+	}
+
+	for _, input := range tests {
+		p := parser.Parse(lexer.Load(input))
+		bytecode, err := compiler.Compile(p.ParseProgram())
+		if err != nil {
+			t.Fatalf("%q: compiler error: %s", input, err)
+		}
+		vm := New(bytecode)
+		if err, ok := vm.Run().(*object.Error); ok {
+			t.Fatalf("%q: %s", input, err.Message)
+		}
+		if vm.sp != bytecode.Main.NumLocals {
+			t.Errorf("%q: stack not balanced. expected sp=%d, got=%d", input, bytecode.Main.NumLocals, vm.sp)
+		}
+	}
+}
+
+func TestCustomBuiltins(t *testing.T) {
+	registry := builtins.NewRegistry(nil)
+	registry.Register(&builtins.Builtin{
+		Name: "answer", MinArgs: 0, MaxArgs: 0,
+		Fn: func(ctx *builtins.Context, args []object.Object) object.Object {
+			return &object.Integer{Value: 42}
+		},
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"answer()", "42"},
+		{"let f = fn() { answer() + 1 }; f()", "43"},
+		{"len([])", "ERROR: identifier not found: len"},
+	}
+
+	for _, tt := range tests {
+		program := parser.Parse(lexer.Load(tt.input)).ParseProgram()
+		bytecode, err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("%q: compiler error: %s", tt.input, err)
+		}
+		if got := NewWithBuiltins(bytecode, registry).Run().Inspect(); got != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -1,20 +1,322 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	user2 "os/user"
 
+	"go_interp/interp/ast"
+	"go_interp/interp/builtins"
+	"go_interp/interp/compiler"
+	"go_interp/interp/diagnostic"
+	"go_interp/interp/evaluator"
+	"go_interp/interp/lexer"
+	"go_interp/interp/object"
+	"go_interp/interp/parser"
 	"go_interp/interp/repl"
+	"go_interp/interp/vm"
 )
 
+// 进程退出码
+const (
+	exitOK = 0
+	// exitRuntimeError 程序执行时出错
+	exitRuntimeError = 1
+	// exitSyntaxError 源码存在词法或语法错误
+	exitSyntaxError = 2
+	// exitUsage 命令行参数有误或无法读写文件
+	exitUsage = 64
+)
+
+// STDIN_NAME 从标准输入读取源码时, 诊断信息中使用的文件名
+const STDIN_NAME = "<stdin>"
+
+// command 一个子命令, 参数为源文件名及其内容
+type command struct {
+	usage string
+	run   func(c *cli, filename, source string) int
+	// flags 定义子命令自己的参数, 没有时为nil
+	flags func(fs *flag.FlagSet, c *cli)
+	// options 用法中源文件之后的参数说明
+	options string
+}
+
+var commands = map[string]command{
+	"run":    {"run a script or a compiled .mkc file", runCommand, engineFlag, " [-engine=eval|vm]"},
+	"build":  {"compile a script to a .mkc bytecode file", buildCommand, buildFlags, " [-o file.mkc]"},
+	"tokens": {"print the tokens of a script", tokensCommand, nil, ""},
+	"ast":    {"print the syntax tree of a script", astCommand, nil, ""},
+	"check":  {"report syntax errors without running", checkCommand, nil, ""},
+}
+
+var commandOrder = []string{"run", "build", "tokens", "ast", "check"}
+
+// engine 执行源码, 返回程序的值或运行时错误(*object.Error);
+// 无法执行时engine自行报告错误, 并返回非0的退出码
+type engine func(c *cli, filename, source string) (object.Object, int)
+
+var engines = map[string]engine{
+	"eval": evalEngine,
+	"vm":   vmEngine,
+}
+
+// cli 子命令执行时的输入输出
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// engineName run子命令使用的执行方式, 为engines中的名字; 为空时由defaultEngine选择
+	engineName string
+	// output build子命令写出的字节码文件, 为空时由源文件名得出
+	output string
+	// builtins 执行脚本时使用的内置函数表, puts和print输出到stdout
+	builtins *builtins.Registry
+}
+
 func main() {
-	user, err := user2.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run 解析命令行并执行, 返回退出码. 没有子命令时启动REPL
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("go_interp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		printUsage(stderr)
+	}
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr, builtins: builtins.NewDefaultRegistry(stdout)}
+	engineFlag(flags, c)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if !c.checkEngine() {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		// REPL使用默认的内置函数表
+		builtins.Default.Out = stdout
+		startREPL(stdin, stdout)
+		return exitOK
+	}
+
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "go_interp: unknown command %q\n", name)
+		printUsage(stderr)
+		return exitUsage
+	}
+	args, err := parseCommandFlags(name, cmd, c, flags.Args()[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "usage: go_interp %s <file.mk | ->%s\n", name, cmd.options)
+		return exitUsage
+	}
+	if !c.checkEngine() {
+		return exitUsage
+	}
 
+	filename, source, err := c.readSource(args[0])
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(stderr, "go_interp: %s\n", err)
+		return exitUsage
+	}
+	return cmd.run(c, filename, source)
+}
+
+// parseCommandFlags 解析子命令的参数, 返回其中的位置参数. 参数可以出现在源文件之前或之后
+func parseCommandFlags(name string, cmd command, c *cli, args []string) ([]string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: go_interp %s <file.mk | ->%s\n", name, cmd.options)
+		fs.PrintDefaults()
+	}
+	if cmd.flags != nil {
+		cmd.flags(fs, c)
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// engineFlag 定义-engine参数; 它既可以写在子命令之前, 也可以作为run子命令的参数
+func engineFlag(fs *flag.FlagSet, c *cli) {
+	fs.StringVar(&c.engineName, "engine", c.engineName,
+		"execution `engine` for run: eval or vm (default vm if the script has a .mkc cache, otherwise eval)")
+}
+
+// checkEngine 检查-engine参数的值, 不认识时将错误写入stderr并返回false
+func (c *cli) checkEngine() bool {
+	if _, ok := engines[c.engineName]; !ok && c.engineName != "" {
+		fmt.Fprintf(c.stderr, "go_interp: unknown engine %q, want eval or vm\n", c.engineName)
+		return false
+	}
+	return true
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: go_interp [-engine=eval|vm] [command <file.mk | ->]\n\n")
+	fmt.Fprintf(w, "Without a command an interactive session is started.\n\ncommands:\n")
+	for _, name := range commandOrder {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+}
+
+func startREPL(in io.Reader, out io.Writer) {
+	// 精简的容器中可能无法获取当前用户, 此时省略用户名
+	if user, err := user2.Current(); err == nil {
+		fmt.Fprintf(out, "Hello %s! This is the Monkey programming language\n", user.Username)
+	} else {
+		fmt.Fprintf(out, "Hello! This is the Monkey programming language\n")
+	}
+	fmt.Fprintf(out, "Feel free to type in commands\n")
+	repl.Start(in, out)
+}
+
+// readSource 读取源文件, 文件名为"-"时读取标准输入
+func (c *cli) readSource(path string) (string, string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(c.stdin)
+		return STDIN_NAME, string(data), err
+	}
+	data, err := os.ReadFile(path)
+	return path, string(data), err
+}
+
+// parse 解析失败时将诊断信息写入stderr并返回nil
+func (c *cli) parse(filename, source string) *ast.Program {
+	p := parser.Parse(lexer.LoadFile(filename, source))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		diagnostic.RenderAll(c.stderr, source, p.Diagnostics())
+		return nil
+	}
+	return program
+}
+
+// runCommand 执行脚本; 文件是build生成的字节码时总在虚拟机中执行
+func runCommand(c *cli, filename, source string) int {
+	if compiler.IsBytecode([]byte(source)) {
+		return c.runBytecode(filename, source)
+	}
+
+	name := c.engineName
+	if name == "" {
+		name = defaultEngine(filename)
+	}
+	result, code := engines[name](c, filename, source)
+	if code != exitOK {
+		return code
+	}
+	return c.report(source, result)
+}
+
+// defaultEngine 没有指定-engine时, 脚本旁边有build生成的字节码缓存则在虚拟机中执行,
+// 使用并更新缓存; 否则直接求值
+func defaultEngine(filename string) string {
+	if filename != STDIN_NAME {
+		if _, err := os.Stat(bytecodePath(filename)); err == nil {
+			return "vm"
+		}
+	}
+	return "eval"
+}
+
+// report 程序出错时将错误写入stderr, 返回退出码
+func (c *cli) report(source string, result object.Object) int {
+	if err, ok := result.(*object.Error); ok {
+		diagnostic.Render(c.stderr, source, err.Diagnostic())
+		return exitRuntimeError
+	}
+	return exitOK
+}
+
+// evalEngine 直接遍历语法树求值
+func evalEngine(c *cli, filename, source string) (object.Object, int) {
+	program := c.parse(filename, source)
+	if program == nil {
+		return nil, exitSyntaxError
+	}
+	return evaluator.Eval(program, object.NewEnvironmentWithBuiltins(c.builtins)), exitOK
+}
+
+// vmEngine 编译为字节码后在虚拟机中执行, 源文件的字节码缓存有效时跳过解析和编译
+func vmEngine(c *cli, filename, source string) (object.Object, int) {
+	f, code := c.loadBytecode(filename, source)
+	if code != exitOK {
+		return nil, code
+	}
+	return vm.NewWithBuiltins(f.Bytecode, c.builtins).Run(), exitOK
+}
+
+func buildFlags(fs *flag.FlagSet, c *cli) {
+	fs.StringVar(&c.output, "o", "", "output `file`, default is the script name with a .mkc extension")
+}
+
+func buildCommand(c *cli, filename, source string) int {
+	output := c.output
+	if output == "" {
+		if filename == STDIN_NAME {
+			fmt.Fprintf(c.stderr, "go_interp: build: -o is required when reading from stdin\n")
+			return exitUsage
+		}
+		output = bytecodePath(filename)
+	}
+
+	f, code := c.compile(filename, source, output)
+	if code != exitOK {
+		return code
+	}
+	if err := writeBytecode(output, f); err != nil {
+		fmt.Fprintf(c.stderr, "go_interp: %s\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func tokensCommand(c *cli, filename, source string) int {
+	diagnostics := repl.PrintTokens(c.stdout, filename, source)
+	if len(diagnostics) != 0 {
+		diagnostic.RenderAll(c.stderr, source, diagnostics)
+		return exitSyntaxError
+	}
+	return exitOK
+}
+
+func astCommand(c *cli, filename, source string) int {
+	program := c.parse(filename, source)
+	if program == nil {
+		return exitSyntaxError
+	}
+	repl.PrintAST(c.stdout, program)
+	return exitOK
+}
+
+func checkCommand(c *cli, filename, source string) int {
+	if c.parse(filename, source) == nil {
+		return exitSyntaxError
 	}
-	fmt.Printf("Hello %s! This is the Monkey programming language\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	return exitOK
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go_interp/interp/compiler"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeScript(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSubcommands(t *testing.T) {
+	good := writeScript(t, "let a = 1 + 2;\na * 3\n")
+	syntax := writeScript(t, "let = 1;\n")
+	runtime := writeScript(t, "let a = 1;\na + true\n")
+
+	tests := []struct {
+		args         []string
+		stdin        string
+		expectedCode int
+		stdout       string
+		stderr       string
+	}{
+		{[]string{"run", good}, "", exitOK, "", ""},
+		{[]string{"run", "-"}, "puts(1, \"a\"); print(2, 3)", exitOK, "1\na\n2 3", ""},
+		{[]string{"check", good}, "", exitOK, "", ""},
+		{[]string{"ast", good}, "", exitOK,
+			"*ast.LetStatement          let a = (1+2);\n*ast.ExpressionStatement   (a*3)\n", ""},
+		{[]string{"tokens", "-"}, "x;", exitOK,
+			"<stdin>:1:1 IDENT          \"x\"\n<stdin>:1:2 ;              \";\"\n", ""},
+		{[]string{"run", syntax}, "", exitSyntaxError, "",
+			syntax + ":1:5: error[P001]: expected next token to be IDENT, but got =\n"},
+		{[]string{"check", "-"}, "let = 1;", exitSyntaxError, "",
+			"<stdin>:1:5: error[P001]: expected next token to be IDENT, but got =\n"},
+		{[]string{"ast", syntax}, "", exitSyntaxError, "", syntax + ":1:5: error[P001]"},
+		{[]string{"tokens", "-"}, "@", exitSyntaxError, "<stdin>:1:1 ILLEGAL        \"@\"\n",
+			"<stdin>:1:1: error[L001]: illegal character '@'\n"},
+		{[]string{"run", runtime}, "", exitRuntimeError, "",
+			runtime + ":2:1: error[R001]: type mismatch: INTEGER + BOOLEAN\n 2 | a + true\n   | ^~~~~~~~\n"},
+		{[]string{"run", "-"}, "let f = fn(x) {\n  x / 0\n};\nf(1)", exitRuntimeError, "",
+			"<stdin>:2:3: error[R004]: division by zero: 1 / 0\n" +
+				" 2 |   x / 0\n" +
+				"   |   ^~~~~\n" +
+				"   = note: in f, called at <stdin>:4:1\n"},
+		{[]string{"-engine=vm", "run", "-"}, "puts(1, \"a\"); print(2, 3)", exitOK, "1\na\n2 3", ""},
+		{[]string{"-engine=vm", "run", runtime}, "", exitRuntimeError, "",
+			runtime + ":2:1: error[R001]: type mismatch: INTEGER + BOOLEAN\n 2 | a + true\n   | ^~~~~~~~\n"},
+		{[]string{"-engine=vm", "run", "-"}, "let f = fn(x) {\n  x / 0\n};\nf(1)", exitRuntimeError, "",
+			"<stdin>:2:3: error[R004]: division by zero: 1 / 0\n" +
+				" 2 |   x / 0\n" +
+				"   |   ^~~~~\n" +
+				"   = note: in f, called at <stdin>:4:1\n"},
+		{[]string{"-engine=vm", "run", syntax}, "", exitSyntaxError, "", syntax + ":1:5: error[P001]"},
+		{[]string{"run", "-engine=vm", runtime}, "", exitRuntimeError, "", runtime + ":2:1: error[R001]"},
+		{[]string{"run", runtime, "-engine=vm"}, "", exitRuntimeError, "", runtime + ":2:1: error[R001]"},
+		{[]string{"-engine=jit", "run", good}, "", exitUsage, "", "go_interp: unknown engine \"jit\", want eval or vm\n"},
+		{[]string{"run", good, "-engine=jit"}, "", exitUsage, "", "go_interp: unknown engine \"jit\", want eval or vm\n"},
+		{[]string{"run"}, "", exitUsage, "", "usage: go_interp run <file.mk | -> [-engine=eval|vm]\n"},
+		{[]string{"run", "a.mk", "b.mk"}, "", exitUsage, "", "usage: go_interp run"},
+		{[]string{"run", filepath.Join(t.TempDir(), "missing.mk")}, "", exitUsage, "", "no such file or directory"},
+		{[]string{"nope"}, "", exitUsage, "", "go_interp: unknown command \"nope\"\n"},
+		{[]string{"-bogus"}, "", exitUsage, "", "flag provided but not defined"},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runCLI(t, tt.stdin, tt.args...)
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d (stderr=%q)", tt.args, tt.expectedCode, code, stderr)
+		}
+		if stdout != tt.stdout {
+			t.Errorf("%v: wrong stdout.\nexpected=%q\ngot=     %q", tt.args, tt.stdout, stdout)
+		}
+		if !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v: wrong stderr.\nexpected=%q\ngot=     %q", tt.args, tt.stderr, stderr)
+		}
+		if tt.stderr == "" && stderr != "" {
+			t.Errorf("%v: unexpected stderr %q", tt.args, stderr)
+		}
+	}
+}
+
+func TestREPLWithoutSubcommand(t *testing.T) {
+	code, stdout, _ := runCLI(t, "1 + 2\n")
+	if code != exitOK {
+		t.Errorf("wrong exit code. got=%d", code)
+	}
+	if !strings.Contains(stdout, "This is the Monkey programming language") {
+		t.Errorf("missing greeting. got=%q", stdout)
+	}
+	if !strings.Contains(stdout, ">> 3\n") {
+		t.Errorf("input was not evaluated. got=%q", stdout)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	script := writeScript(t, "let f = fn(x) {\n  x / 0\n};\nputs(\"hi\");\nf(1)\n")
+
+	code, _, stderr := runCLI(t, "", "build", script)
+	if code != exitOK {
+		t.Fatalf("build failed with %d: %s", code, stderr)
+	}
+	compiled := bytecodePath(script)
+	if !strings.HasSuffix(compiled, "main.mkc") {
+		t.Fatalf("wrong bytecode path %q", compiled)
+	}
+
+	output := filepath.Join(t.TempDir(), "out.mkc")
+	if code, _, stderr := runCLI(t, "", "build", script, "-o", output); code != exitOK {
+		t.Fatalf("build -o failed with %d: %s", code, stderr)
+	}
+
+	// 字节码文件的执行结果和错误信息与直接执行脚本相同
+	_, expectedStdout, expectedStderr := runCLI(t, "", "run", script)
+	for _, path := range []string{compiled, output} {
+		for _, engine := range []string{"-engine=eval", "-engine=vm"} {
+			code, stdout, stderr := runCLI(t, "", engine, "run", path)
+			if code != exitRuntimeError || stdout != expectedStdout || stderr != expectedStderr {
+				t.Errorf("%s run %s: got code=%d stdout=%q stderr=%q", engine, path, code, stdout, stderr)
+			}
+		}
+	}
+
+	tests := []struct {
+		args         []string
+		stdin        string
+		expectedCode int
+		stderr       string
+	}{
+		{[]string{"build", "-"}, "1", exitUsage, "go_interp: build: -o is required when reading from stdin\n"},
+		{[]string{"build", "-o", filepath.Join(t.TempDir(), "stdin.mkc"), "-"}, "1", exitOK, ""},
+		{[]string{"build", "-o", filepath.Join(t.TempDir(), "bad.mkc"), "-"}, "let = 1;", exitSyntaxError, "<stdin>:1:5: error[P001]"},
+		{[]string{"build"}, "", exitUsage, "usage: go_interp build <file.mk | -> [-o file.mkc]\n"},
+		{[]string{"build", script, "-x"}, "", exitUsage, "flag provided but not defined: -x"},
+		{[]string{"build", script, "-o", filepath.Join(t.TempDir(), "missing", "a.mkc")}, "", exitUsage,
+			"no such file or directory"},
+		{[]string{"run", "-"}, compiler.Magic + "\x00", exitUsage, "<stdin>: not a valid bytecode file"},
+	}
+
+	for _, tt := range tests {
+		code, _, stderr := runCLI(t, tt.stdin, tt.args...)
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. expected=%d, got=%d (stderr=%q)", tt.args, tt.expectedCode, code, stderr)
+		}
+		if !strings.Contains(stderr, tt.stderr) || (tt.stderr == "" && stderr != "") {
+			t.Errorf("%v: wrong stderr.\nexpected=%q\ngot=     %q", tt.args, tt.stderr, stderr)
+		}
+	}
+}
+
+func TestBytecodeCache(t *testing.T) {
+	script := writeScript(t, "puts(1)")
+	cache := bytecodePath(script)
+
+	// 没有执行过build时不创建缓存
+	if code, stdout, _ := runCLI(t, "", "-engine=vm", "run", script); code != exitOK || stdout != "1\n" {
+		t.Fatalf("run failed: code=%d stdout=%q", code, stdout)
+	}
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Fatalf("run created a cache: %v", err)
+	}
+
+	if code, _, stderr := runCLI(t, "", "build", script); code != exitOK {
+		t.Fatalf("build failed with %d: %s", code, stderr)
+	}
+
+	// 缓存与源码一致时直接使用: 换成另一个程序的字节码, 但保留源码的哈希
+	other := writeScript(t, "puts(2)")
+	runCLI(t, "", "build", other, "-o", cache)
+	f, err := readBytecode(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SourceHash = compiler.HashSource("puts(1)")
+	f.Filename = script
+	if err := writeBytecode(cache, f); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := runCLI(t, "", "-engine=vm", "run", script); stdout != "2\n" {
+		t.Errorf("cache was not used. got stdout=%q", stdout)
+	}
+	// 没有指定-engine时同样使用缓存, 指定-engine=eval时总是直接求值
+	if _, stdout, _ := runCLI(t, "", "run", script); stdout != "2\n" {
+		t.Errorf("cache was not used by default. got stdout=%q", stdout)
+	}
+	if _, stdout, _ := runCLI(t, "", "-engine=eval", "run", script); stdout != "1\n" {
+		t.Errorf("cache was used by -engine=eval. got stdout=%q", stdout)
+	}
+	// -engine也可以作为run的参数, 写在脚本之前或之后
+	for _, args := range [][]string{{"run", "-engine=vm", script}, {"run", script, "-engine=vm"}} {
+		if _, stdout, _ := runCLI(t, "", args...); stdout != "2\n" {
+			t.Errorf("%v: cache was not used. got stdout=%q", args, stdout)
+		}
+	}
+
+	// 源码修改后缓存失效, 重新编译并更新缓存
+	if err := os.WriteFile(script, []byte("puts(3)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := runCLI(t, "", "-engine=vm", "run", script); stdout != "3\n" {
+		t.Errorf("stale cache was used. got stdout=%q", stdout)
+	}
+	if f, err := readBytecode(cache); err != nil || f.SourceHash != compiler.HashSource("puts(3)") {
+		t.Errorf("cache was not updated: %v", err)
+	}
+
+	// 直接执行字节码文件时同样检查源码
+	if err := os.WriteFile(script, []byte("puts(4)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := runCLI(t, "", "run", cache); stdout != "4\n" {
+		t.Errorf("stale bytecode file was run. got stdout=%q", stdout)
+	}
+
+	// 损坏的缓存被忽略并重新生成
+	if err := os.WriteFile(cache, []byte(compiler.Magic+"garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, _ := runCLI(t, "", "-engine=vm", "run", script); stdout != "4\n" {
+		t.Errorf("corrupt cache was used. got stdout=%q", stdout)
+	}
+	if _, err := readBytecode(cache); err != nil {
+		t.Errorf("corrupt cache was not rebuilt: %v", err)
+	}
+}
+
+func TestBytecodeFromOtherDirectory(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// 在脚本所在的目录中用相对路径编译
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "b.mk"), []byte("puts(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runCLI(t, "", "build", "b.mk"); code != exitOK {
+		t.Fatalf("build failed with %d: %s", code, stderr)
+	}
+	compiled := filepath.Join(dir, "b.mkc")
+	data, err := os.ReadFile(compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个目录中有同名但无关的脚本, 它不能被当作源文件重新编译
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "b.mk"), []byte("puts(99)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, stderr := runCLI(t, "", "run", compiled); stdout != "1\n" {
+		t.Errorf("wrong source was used. got stdout=%q stderr=%q", stdout, stderr)
+	}
+	if after, err := os.ReadFile(compiled); err != nil || !bytes.Equal(after, data) {
+		t.Errorf("bytecode file was rewritten: %v", err)
+	}
+
+	// 字节码文件旁边的源文件修改后, 从其他目录执行时同样重新编译
+	if err := os.WriteFile(filepath.Join(dir, "b.mk"), []byte("puts(2)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stdout, stderr := runCLI(t, "", "run", compiled); stdout != "2\n" {
+		t.Errorf("stale bytecode file was run. got stdout=%q stderr=%q", stdout, stderr)
+	}
+	if f, err := readBytecode(compiled); err != nil || f.SourceHash != compiler.HashSource("puts(2)") || f.SourcePath != "b.mk" {
+		t.Errorf("bytecode file was not updated: %+v, %v", f, err)
+	}
+}
@@ -0,0 +1,41 @@
+package token
+
+import "fmt"
+
+// Pos 源码中的位置. Line和Column从1开始计数, Offset为字节偏移(从0开始)
+type Pos struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position has been set by the lexer.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns "file:line:col", "line:col" when there is no file name,
+// or "-" for an invalid position.
+func (p Pos) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span 半开区间[Start, End), End指向最后一个字符之后的位置
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func (s Span) String() string {
+	return s.Start.String()
+}
@@ -7,8 +7,14 @@ const (
 	EOF     TokenType = "EOF"
 
 	// 标识符+字面量
-	IDENT TokenType = "IDENT"
-	INT   TokenType = "INT"
+	IDENT  TokenType = "IDENT"
+	INT    TokenType = "INT"
+	FLOAT  TokenType = "FLOAT"
+	STRING TokenType = "STRING"
+	// INTERP_STRING 含有${...}插值的字符串, Literal为包括引号在内的源码
+	INTERP_STRING TokenType = "INTERP_STRING"
+	// COMMENT 仅在词法分析器开启注释输出时产生
+	COMMENT TokenType = "COMMENT"
 
 	// 运算符
 	ASSIGN   TokenType = "="
@@ -17,11 +23,32 @@ const (
 	BANG     TokenType = "!"
 	ASTERISK TokenType = "*"
 	SLASH    TokenType = "/"
+	PERCENT  TokenType = "%"
 	EQ       TokenType = "=="
 	NOT_EQ   TokenType = "!="
 
-	LT TokenType = "<"
-	GT TokenType = ">"
+	LT    TokenType = "<"
+	GT    TokenType = ">"
+	LT_EQ TokenType = "<="
+	GT_EQ TokenType = ">="
+
+	// 复合赋值运算符
+	PLUS_ASSIGN     TokenType = "+="
+	MINUS_ASSIGN    TokenType = "-="
+	ASTERISK_ASSIGN TokenType = "*="
+	SLASH_ASSIGN    TokenType = "/="
+
+	// 逻辑运算符
+	AND TokenType = "&&"
+	OR  TokenType = "||"
+
+	// 位运算符
+	BIT_AND TokenType = "&"
+	BIT_OR  TokenType = "|"
+	BIT_XOR TokenType = "^"
+	BIT_NOT TokenType = "~"
+	SHL     TokenType = "<<"
+	SHR     TokenType = ">>"
 
 	// 分隔符
 	COMMA     TokenType = ","
@@ -30,6 +57,9 @@ const (
 	RPAREN    TokenType = ")"
 	LBRACE    TokenType = "{"
 	RBRACE    TokenType = "}"
+	LBRACKET  TokenType = "["
+	RBRACKET  TokenType = "]"
+	COLON     TokenType = ":"
 
 	// 关键字
 	FUNCTION TokenType = "FUNCTION"
@@ -39,24 +69,41 @@ const (
 	IF       TokenType = "IF"
 	ELSE     TokenType = "ELSE"
 	RETURN   TokenType = "RETURN"
+	WHILE    TokenType = "WHILE"
+	FOR      TokenType = "FOR"
+	IN       TokenType = "IN"
+	BREAK    TokenType = "BREAK"
+	CONTINUE TokenType = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Pos // 词法单元第一个字符的位置
+	End     Pos // 词法单元最后一个字符之后的位置
+}
+
+// Span returns the source range covered by the token.
+func (t Token) Span() Span {
+	return Span{Start: t.Pos, End: t.End}
 }
 
-func NewToken(tokenType TokenType, ch byte) Token {
+func NewToken(tokenType TokenType, ch rune) Token {
 	return Token{Type: tokenType, Literal: string(ch)}
 }
 
@@ -1,9 +1,18 @@
 package util
 
-func IsLetter(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+import "unicode"
+
+// IsLetter 可以出现在标识符开头的字符: Unicode字母或下划线
+func IsLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
+// IsDigit 可以出现在标识符中(非开头)的数字, 包括其他文字中的数字
+func IsDigit(ch rune) bool {
+	return unicode.IsDigit(ch)
 }
 
-func IsDigit(ch byte) bool {
+// IsDecimal 数字字面量中的十进制数字, 只接受ASCII的0-9
+func IsDecimal(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }